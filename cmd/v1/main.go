@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -11,17 +12,113 @@ import (
 
 	"github.com/clh021/generator/pkg/config"
 	"github.com/clh021/generator/pkg/generator"
+	"github.com/clh021/generator/pkg/utils"
+	"github.com/clh021/generator/pkg/variables"
 )
 
 var versionCmd = flag.NewFlagSet("version", flag.ExitOnError)
 
+// stringList 可重复指定的字符串参数
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// setArg 一个变量覆盖参数（-set, -set-string, -set-json, -set-file）
+type setArg struct {
+	flag  string
+	value string
+}
+
+// setFlag 变量覆盖参数，不同类型的参数共享同一个列表以保留命令行中的先后顺序
+type setFlag struct {
+	name string
+	args *[]setArg
+}
+
+func (s setFlag) String() string {
+	return ""
+}
+
+func (s setFlag) Set(value string) error {
+	*s.args = append(*s.args, setArg{flag: s.name, value: value})
+	return nil
+}
+
+// parseOverrides 按命令行顺序解析变量覆盖参数，-set-file 的相对路径相对于工作目录
+func parseOverrides(args []setArg, workDir string) ([]variables.Override, error) {
+	var overrides []variables.Override
+	for _, arg := range args {
+		var parsed []variables.Override
+		var err error
+		switch arg.flag {
+		case "set":
+			parsed, err = variables.ParseSet(arg.value)
+		case "set-string":
+			parsed, err = variables.ParseSetString(arg.value)
+		case "set-json":
+			parsed, err = variables.ParseSetJSON(arg.value)
+		case "set-file":
+			parsed, err = variables.ParseSetFile(arg.value, workDir)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("-%s %s: %w", arg.flag, arg.value, err)
+		}
+		overrides = append(overrides, parsed...)
+	}
+	return overrides, nil
+}
+
 func main() {
 	workDir := flag.String("dir", ".", "工作目录路径")
-	templateDir := flag.String("template", ".gen_templates", "模板目录路径")
-	variablesDir := flag.String("variables", ".gen_variables", "变量目录路径")
-	outputDir := flag.String("output", ".gen_output", "输出目录路径")
+	configFile := flag.String("config", "", "配置文件路径，默认从工作目录向上查找 "+config.ConfigFileName)
+	templateDir := flag.String("template", config.DefaultTemplateDir, "模板目录路径")
+	variablesDir := flag.String("variables", config.DefaultVariablesDir, "变量目录路径")
+	outputDir := flag.String("output", config.DefaultOutputDir, "输出目录路径")
 	quickStart := flag.Bool("quickstart", false, "生成快速开始示例")
-	variableFiles := flag.String("varfiles", "", "变量文件路径，多个文件用逗号分隔")
+	variableFiles := flag.String("varfiles", "", "变量文件路径，多个文件用逗号分隔；支持 yaml、json、toml、env 格式，按扩展名识别，也可以用 @格式 后缀指定，如 vars.txt@toml")
+	skipSuffixes := flag.String("skip-suffixes", "", "跳过特定后缀的模板文件，多个后缀用逗号分隔，完整路径(path)进行匹配")
+	skipPrefixes := flag.String("skip-prefixes", "", "跳过特定前缀路径的模板文件，多个前缀用逗号分隔，相对于模板目录，不要前置/符号")
+	var includes, excludes, profiles, sandboxRoots, allowOutside, secretKeys, skipEmptyTemplates stringList
+	flag.Var(&includes, "include", "只生成匹配的模板（glob 模式，支持 **，匹配相对于模板目录的路径），可重复指定")
+	dryRun := flag.Bool("dry-run", false, "只列出将要生成的文件及其状态（new/changed/unchanged），不写入文件")
+	showDiff := flag.Bool("diff", false, "输出现有文件与生成内容之间的统一差异，不写入文件；存在差异时以非零状态退出")
+	writePolicy := flag.String("write-policy", "", "目标文件已存在时的写入策略: overwrite(默认), skip-if-exists, fail-if-exists, write-if-changed, backup, backup-timestamp, merge")
+	allowOrphanedRegions := flag.Bool("allow-orphaned-regions", false, "现有文件中的保留区域(gen:keep)在模板中不存在时只报告而不报错")
+	prune := flag.Bool("prune", false, "删除上次生成清单("+generator.ManifestFileName+")中记录、但本次未生成的过期文件；生成后被修改过的文件不会删除")
+	force := flag.Bool("force", false, "与 -prune 一起使用，同时删除生成后被修改过的过期文件")
+	listMerge := flag.String("list-merge", "", "合并多个变量文件时列表的合并策略: replace(默认), append, merge-by-key[:字段]")
+	schemaFile := flag.String("schema", "", "变量校验规则文件（JSON Schema 子集，YAML 或 JSON 格式），默认使用模板目录中的 .gen_schema.yaml/.yml/.json")
+	envPrefix := flag.String("env-prefix", "", "从带此前缀的环境变量中读取模板变量，如 GEN_；__ 表示嵌套，GEN_PROJECT__NAME 对应 project.name，优先级高于变量文件")
+	questionsFile := flag.String("questions", "", "问题文件，声明需要交互输入的变量，默认使用模板目录中的 "+generator.QuestionsFileName)
+	noInput := flag.Bool("no-input", false, "不交互询问缺少的变量，使用问题中的默认值，没有默认值时报错；标准输入不是终端时总是如此")
+	saveAnswers := flag.Bool("save-answers", false, "将交互输入的回答保存到变量目录的 "+generator.AnswersFileName+" 中，下次生成时直接使用")
+	jobs := flag.Int("jobs", 0, "并发渲染模板的数量，0 表示使用 CPU 核数")
+	noManifest := flag.Bool("no-manifest", false, "不在输出目录中写入生成清单 "+generator.ManifestFileName)
+	var policyRules, eachRules stringList
+	flag.Var(&policyRules, "policy", "为匹配的模板指定写入策略，格式为 <glob模式>=<策略>，可重复指定，第一个匹配的规则生效")
+	flag.Var(&eachRules, "each", "对列表变量中的每一项各生成一个文件，格式为 <glob模式>=<变量路径> [as <名称>]，如 'handlers/**=routes as route'，可重复指定，第一个匹配的规则生效")
+	var setArgs []setArg
+	flag.Var(setFlag{"set", &setArgs}, "set", "设置变量，覆盖变量文件中的值，格式为 path=value[,path=value]，如 project.name=Foo；值按类型解析，{a,b} 表示列表，字符串中的 ${路径} 引用其他变量，$${ 表示字符 ${，可重复指定")
+	flag.Var(setFlag{"set-string", &setArgs}, "set-string", "同 -set，但值总是作为字符串，其中的 ${ 不展开，可重复指定")
+	flag.Var(setFlag{"set-json", &setArgs}, "set-json", "设置变量为 JSON 值，格式为 path=<json>，如 routes='[{\"name\":\"users\"}]'，可重复指定")
+	flag.Var(setFlag{"set-file", &setArgs}, "set-file", "设置变量为文件内容，格式为 path=<文件路径>，相对路径相对于工作目录，内容中的 ${路径} 引用其他变量，$${ 表示字符 ${，可重复指定")
+	flag.Var(&excludes, "exclude", "跳过匹配的模板（glob 模式，支持 **，匹配相对于模板目录的路径），可重复指定")
+	flag.Var(&profiles, "profile", "叠加变量目录中 profiles/<名称>/ 下的变量文件，可重复指定或用逗号分隔，后面的覆盖前面的；模板中可用 .__profile 获取当前的变量配置")
+	useSandbox := flag.Bool("sandbox", false, "限制模板函数 file 和 include 只能访问模板目录、变量目录和 -sandbox-root 指定的目录，使用他人提供的模板时建议启用")
+	flag.Var(&sandboxRoots, "sandbox-root", "启用沙箱时额外允许模板访问的目录，相对路径相对于工作目录，可重复指定")
+	flag.Var(&allowOutside, "allow-outside-output", "允许匹配的模板（glob 模式，支持 **，匹配相对于模板目录的路径）写入输出目录之外，可重复指定")
+	flag.Var(&secretKeys, "secret-key", "视为机密的变量键名模式（如 *password*，不区分大小写），诊断信息和 vars 输出中隐藏其值，可重复指定或用逗号分隔；指定后替换默认的模式")
+	strictPathVariables := flag.Bool("strict-path-variables", false, "输出路径中的变量（如 __project.name__）不存在或无法转换为字符串时生成失败，而不是警告并保留原始字符串")
+	skipEmpty := flag.Bool("skip-empty", false, "不写入内容只包含空白的文件，并删除上次生成（记录在生成清单中）且之后未被修改的同名文件")
+	flag.Var(&skipEmptyTemplates, "skip-empty-template", "只对匹配的模板（glob 模式，支持 **，匹配相对于模板目录的路径）启用 -skip-empty，可重复指定")
+	jsonOutput := flag.Bool("json", false, "与 vars 子命令一起使用，以 JSON 格式输出变量及其来源")
 
 	// 定义 version 子命令
 	if len(os.Args) > 1 && os.Args[1] == "version" {
@@ -30,7 +127,13 @@ func main() {
 		os.Exit(0)
 	}
 
-	flag.Parse()
+	// vars 子命令输出合并后的变量及每个值的来源，接受与生成时相同的选项
+	args := os.Args[1:]
+	showVars := len(args) > 0 && args[0] == "vars"
+	if showVars {
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
 
 	if *quickStart {
 		if err := generateQuickStartExample(); err != nil {
@@ -39,82 +142,364 @@ func main() {
 		return
 	}
 
-	// 获取工作目录的绝对路径
-	absPath, err := os.Getwd()
+	// 按 默认值 < 配置文件 < 环境变量 加载配置
+	cfg, usedConfigFile, err := config.Load(*workDir, *configFile)
 	if err != nil {
-		log.Fatalf("获取工作目录失败: %v", err)
+		log.Fatalf("加载配置失败: %+v", err)
 	}
 
-	if *workDir != "." {
-		absPath = *workDir
+	// 命令行参数优先级最高，只覆盖显式指定的参数
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+	var flagCfg config.Config
+	if setFlags["template"] {
+		flagCfg.TemplateDir = *templateDir
+	}
+	if setFlags["variables"] {
+		flagCfg.VariablesDir = *variablesDir
+	}
+	if setFlags["output"] {
+		flagCfg.OutputDir = *outputDir
+	}
+	if setFlags["varfiles"] {
+		flagCfg.VariableFiles = config.SplitList(*variableFiles)
+	}
+	if setFlags["profile"] {
+		flagCfg.Profiles = config.SplitList(profiles.String())
+	}
+	if setFlags["skip-suffixes"] {
+		flagCfg.SkipTemplateSuffixes = *skipSuffixes
+	}
+	if setFlags["skip-prefixes"] {
+		flagCfg.SkipTemplatePrefixes = *skipPrefixes
+	}
+	if setFlags["include"] {
+		flagCfg.Include = includes
+	}
+	if setFlags["exclude"] {
+		flagCfg.Exclude = excludes
+	}
+	if setFlags["write-policy"] {
+		flagCfg.WritePolicy = *writePolicy
+	}
+	if setFlags["allow-orphaned-regions"] {
+		flagCfg.AllowOrphanedRegions = *allowOrphanedRegions
+		flagCfg.MarkSet("allow_orphaned_regions")
+	}
+	if setFlags["list-merge"] {
+		flagCfg.ListMerge = *listMerge
+	}
+	if setFlags["schema"] {
+		flagCfg.SchemaFile = *schemaFile
+	}
+	if setFlags["env-prefix"] {
+		flagCfg.VariableEnvPrefix = *envPrefix
+	}
+	if setFlags["questions"] {
+		flagCfg.QuestionsFile = *questionsFile
+	}
+	if setFlags["save-answers"] {
+		flagCfg.SaveAnswers = *saveAnswers
+		flagCfg.MarkSet("save_answers")
+	}
+	if setFlags["sandbox"] {
+		flagCfg.Sandbox = *useSandbox
+		flagCfg.MarkSet("sandbox")
+	}
+	if setFlags["sandbox-root"] {
+		flagCfg.SandboxRoots = sandboxRoots
+	}
+	if setFlags["allow-outside-output"] {
+		flagCfg.AllowOutsideOutput = allowOutside
+	}
+	if setFlags["strict-path-variables"] {
+		flagCfg.StrictPathVariables = *strictPathVariables
+		flagCfg.MarkSet("strict_path_variables")
+	}
+	if setFlags["secret-key"] {
+		flagCfg.SecretKeys = config.SplitList(secretKeys.String())
+	}
+	if setFlags["skip-empty"] {
+		flagCfg.SkipEmpty = *skipEmpty
+		flagCfg.MarkSet("skip_empty")
+	}
+	if setFlags["skip-empty-template"] {
+		flagCfg.SkipEmptyTemplates = skipEmptyTemplates
+	}
+	if setFlags["jobs"] {
+		flagCfg.Jobs = *jobs
+		flagCfg.MarkSet("jobs")
+	}
+	if setFlags["no-manifest"] {
+		flagCfg.DisableManifest = *noManifest
+		flagCfg.MarkSet("disable_manifest")
+	}
+	if setFlags["policy"] {
+		for _, rule := range policyRules {
+			pattern, policy, ok := strings.Cut(rule, "=")
+			if !ok {
+				log.Fatalf("无效的写入策略规则 %q，格式应为 <glob模式>=<策略>", rule)
+			}
+			flagCfg.WritePolicies = append(flagCfg.WritePolicies, config.WritePolicyRule{Pattern: pattern, Policy: policy})
+		}
+	}
+	if setFlags["each"] {
+		for _, rule := range eachRules {
+			pattern, each, ok := strings.Cut(rule, "=")
+			if !ok {
+				log.Fatalf("无效的 each 规则 %q，格式应为 <glob模式>=<变量路径> [as <名称>]", rule)
+			}
+			flagCfg.Each = append(flagCfg.Each, config.EachRule{Pattern: pattern, Each: each})
+		}
 	}
+	// 相对路径相对于工作目录
+	cfg.Merge(flagCfg.Resolve(*workDir))
 
-	// 检查默认变量目录是否存在
-	defaultVariablesPath := filepath.Join(absPath, *variablesDir)
-	if _, err := os.Stat(defaultVariablesPath); os.IsNotExist(err) && flag.NFlag() == 0 {
+	// 检查变量目录是否存在
+	if _, err := os.Stat(cfg.VariablesDir); os.IsNotExist(err) && flag.NFlag() == 0 && usedConfigFile == "" {
 		fmt.Println("未找到默认变量目录，且未提供任何参数。")
 		printHelp()
 		os.Exit(1)
 	}
 
-	// 创建配置
-	cfg := &config.Config{
-		TemplateDir:   *templateDir,
-		VariablesDir:  *variablesDir,
-		OutputDir:     *outputDir,
-		VariableFiles: []string{},
+	// 打印配置信息以便调试
+	if usedConfigFile != "" {
+		log.Printf("使用的配置文件: %s", usedConfigFile)
+	}
+	log.Printf("使用的配置：\n模板目录: %s\n变量目录: %s\n输出目录: %s\n变量文件: %v\n变量配置: %v",
+		cfg.TemplateDir, cfg.VariablesDir, cfg.OutputDir, cfg.VariableFiles, cfg.Profiles)
+
+	// 命令行变量覆盖，按指定顺序应用在所有变量文件之后
+	overrides, err := parseOverrides(setArgs, *workDir)
+	if err != nil {
+		log.Fatalf("解析变量覆盖失败: %v", err)
 	}
 
-	if *variableFiles != "" {
-		cfg.VariableFiles = strings.Split(*variableFiles, ",")
+	gen := generator.NewGenerator().WithVariableOverrides(overrides)
+	// 只在终端中交互询问，提示输出到标准错误以免与 -diff 等输出混在一起
+	if !*noInput && utils.IsTerminal(os.Stdin) {
+		gen.WithPrompter(generator.NewTerminalPrompter(os.Stdin, os.Stderr))
 	}
-	// 如果提供了工作目录，则将路径调整为相对于工作目录
-	if *workDir != "." {
-		cfg.TemplateDir = filepath.Join(*workDir, *templateDir)
-		cfg.VariablesDir = filepath.Join(*workDir, *variablesDir)
-		cfg.OutputDir = filepath.Join(*workDir, *outputDir)
-		for i, file := range cfg.VariableFiles {
-			cfg.VariableFiles[i] = filepath.Join(*workDir, file)
+	if showVars {
+		if err := printVariables(gen, cfg, *jsonOutput); err != nil {
+			log.Fatalf("加载变量失败: %+v", err)
 		}
+		return
 	}
 
-	// 打印配置信息以便调试
-	log.Printf("使用的配置：\n模板目录: %s\n变量目录: %s\n输出目录: %s\n变量文件: %v",
-		cfg.TemplateDir, cfg.VariablesDir, cfg.OutputDir, cfg.VariableFiles)
-
-	gen := generator.NewGenerator()
 	files, err := gen.GenerateFiles(cfg)
 	if err != nil {
 		log.Fatalf("生成失败: %+v", err)
 	}
 
+	// 只检查不写入
+	if *dryRun || *showDiff {
+		changed, err := reportFiles(files, cfg.OutputDir, generator.WritePolicy(cfg.WritePolicy), *showDiff)
+		if err != nil {
+			log.Fatalf("检查生成结果失败: %+v", err)
+		}
+		if *prune {
+			if err := pruneFiles(gen, files, *force, true); err != nil {
+				log.Fatalf("检查过期文件失败: %+v", err)
+			}
+		}
+		if *showDiff && changed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 写入生成的文件
+	results, err := gen.WriteFiles(files)
+	for _, result := range results {
+		for _, region := range result.OrphanedRegions {
+			log.Printf("警告: %s 中的保留区域 %s (第 %d 行) 在模板中已不存在，其内容未被保留", result.OutputPath, region.Name, region.Line)
+		}
+		if result.Conflicts > 0 {
+			log.Printf("警告: %s 中有 %d 处合并冲突，请手动解决冲突标记", result.OutputPath, result.Conflicts)
+		}
+		switch result.Action {
+		case generator.WriteActionSkipped:
+			log.Printf("已跳过现有文件: %s", result.OutputPath)
+		case generator.WriteActionUnchanged:
+			log.Printf("内容未变化: %s", result.OutputPath)
+		case generator.WriteActionDropped:
+			log.Printf("内容为空，未写入: %s", result.OutputPath)
+		case generator.WriteActionRemoved:
+			log.Printf("内容为空，已删除上次生成的文件: %s", result.OutputPath)
+		case generator.WriteActionKeptModified:
+			log.Printf("警告: 内容为空，但 %s 在生成后被修改过，未删除（使用 -prune -force 删除）", result.OutputPath)
+		default:
+			if result.BackupPath != "" {
+				log.Printf("已备份现有文件: %s", result.BackupPath)
+			}
+			log.Printf("已写入文件: %s", result.OutputPath)
+		}
+	}
+	if err != nil {
+		log.Fatalf("写入失败: %+v", err)
+	}
+
+	if *prune {
+		if err := pruneFiles(gen, files, *force, false); err != nil {
+			log.Fatalf("清理过期文件失败: %+v", err)
+		}
+	}
+
+	log.Println("生成完成")
+}
+
+// printVariables 输出合并后的变量，每个键标注其值的来源；asJSON 为 true 时输出 JSON：
+//
+//	{"variables": {...}, "origins": {"server.port": {"file": "...", "line": 2, "column": 3}}}
+//
+// 机密变量的值显示为 ******
+func printVariables(gen *generator.Generator, cfg *config.Config, asJSON bool) error {
+	vars, provenance, err := gen.Variables(cfg)
+	if err != nil {
+		return err
+	}
+	vars = gen.Secrets().Redact(vars)
+	if !asJSON {
+		return variables.EncodeAnnotatedYAML(os.Stdout, vars, provenance)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Variables map[string]interface{}      `json:"variables"`
+		Origins   map[string]variables.Origin `json:"origins"`
+	}{vars, provenance.Origins()})
+}
+
+// pruneFiles 清理上次生成清单中的过期文件并输出结果，dryRun 为 true 时只列出将被删除的文件
+func pruneFiles(gen *generator.Generator, files []generator.GeneratedFile, force, dryRun bool) error {
+	results, err := gen.Prune(files, force, dryRun)
+	for _, result := range results {
+		switch result.Action {
+		case generator.PruneActionDeleted:
+			if dryRun {
+				fmt.Printf("%-12s %s\n", "[prune]", result.Path)
+			} else {
+				log.Printf("已删除过期文件: %s", result.Path)
+			}
+		case generator.PruneActionModified:
+			log.Printf("警告: 过期文件 %s 在生成后被修改过，未删除（使用 -force 强制删除）", result.Path)
+		case generator.PruneActionMissing:
+			log.Printf("过期文件已不存在: %s", result.Path)
+		case generator.PruneActionRejected:
+			log.Printf("警告: 生成清单中的路径 %s 不在输出目录中，未删除", result.Path)
+		}
+	}
+	return err
+}
+
+// reportFiles 输出每个生成文件的状态，showDiff 为 true 时同时输出统一差异
+// defaultPolicy 为文件未指定写入策略时使用的策略
+// 返回新增或变更的文件数量
+func reportFiles(files []generator.GeneratedFile, outputDir string, defaultPolicy generator.WritePolicy, showDiff bool) (int, error) {
+	counts := make(map[generator.FileStatus]int)
+	dropped, skipped := 0, 0
 	for _, file := range files {
-		// 创建输出目录
-		outputDir := filepath.Dir(file.OutputPath)
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			log.Fatalf("创建输出目录失败: %v", err)
+		// 内容只包含空白的文件不会写入
+		if file.Dropped() {
+			dropped++
+			if !showDiff {
+				fmt.Printf("%-12s %s\n", "[empty]", file.OutputPath)
+			}
+			continue
+		}
+
+		policy := file.WritePolicy
+		if policy == "" {
+			policy = defaultPolicy
+		}
+		policy, err := generator.ParseWritePolicy(string(policy))
+		if err != nil {
+			return 0, err
+		}
+
+		status, existing, err := file.Status()
+		if err != nil {
+			return 0, err
 		}
 
-		// 创建输出文件
-		if err := os.WriteFile(file.OutputPath, []byte(file.Content), 0644); err != nil {
-			log.Fatalf("写入文件失败: %v", err)
+		// 与写入时一致，已存在的文件先按写入策略跳过或报错，
+		// 再合并现有文件的修改并注入其中的保留区域
+		if status != generator.FileStatusNew {
+			switch policy {
+			case generator.WritePolicySkipIfExists:
+				skipped++
+				if !showDiff {
+					fmt.Printf("%-12s %s\n", "[skipped]", file.OutputPath)
+				}
+				continue
+			case generator.WritePolicyFailIfExists:
+				return 0, fmt.Errorf("%s: %w", file.OutputPath, generator.ErrOutputExists)
+			}
+
+			merged, orphaned, conflicts, err := file.MergeExisting(existing, policy)
+			if err != nil {
+				return 0, err
+			}
+			for _, region := range orphaned {
+				fmt.Fprintf(os.Stderr, "警告: %s 中的保留区域 %s (第 %d 行) 在模板中已不存在\n", file.OutputPath, region.Name, region.Line)
+			}
+			if conflicts > 0 {
+				fmt.Fprintf(os.Stderr, "警告: %s 合并时将产生 %d 处冲突\n", file.OutputPath, conflicts)
+			}
+			file = merged
+			if file.Content == existing {
+				status = generator.FileStatusUnchanged
+			} else {
+				status = generator.FileStatusChanged
+			}
 		}
+		counts[status]++
 
-		log.Printf("已写入文件: %s", file.OutputPath)
+		name := file.OutputPath
+		if rel, err := filepath.Rel(outputDir, file.OutputPath); err == nil {
+			name = rel
+		}
+
+		if !showDiff {
+			fmt.Printf("%-12s %s\n", "["+string(status)+"]", file.OutputPath)
+			continue
+		}
+
+		switch status {
+		case generator.FileStatusNew:
+			fmt.Print(generator.UnifiedDiff("/dev/null", "b/"+filepath.ToSlash(name), "", file.Content))
+		case generator.FileStatusChanged:
+			fmt.Print(generator.UnifiedDiff("a/"+filepath.ToSlash(name), "b/"+filepath.ToSlash(name), existing, file.Content))
+		}
 	}
 
-	log.Println("生成完成")
+	changed := counts[generator.FileStatusNew] + counts[generator.FileStatusChanged]
+	fmt.Fprintf(os.Stderr, "共 %d 个文件: 新增 %d, 变更 %d, 未变更 %d, 跳过 %d, 内容为空 %d\n", len(files),
+		counts[generator.FileStatusNew], counts[generator.FileStatusChanged], counts[generator.FileStatusUnchanged], skipped, dropped)
+	return changed, nil
 }
 
 func printHelp() {
 	fmt.Println("使用方法: generator [选项]")
+	fmt.Println("       generator vars [选项] [-json]   # 输出合并后的变量及每个值的来源")
 	fmt.Println("\n选项:")
 	flag.PrintDefaults()
 	fmt.Println("\n示例:")
 	fmt.Println("  generator -quickstart                # 生成快速开始示例")
 	fmt.Println("  generator -dir /path/to/workdir      # 指定工作目录")
+	fmt.Println("  generator -config /path/to/.gen_config.yaml  # 指定配置文件")
 	fmt.Println("  generator -template /path/to/templates -variables /path/to/variables -output /path/to/output") //
+	fmt.Println("  generator -skip-prefixes=web                # 跳过 web 目录下的模板")
+	fmt.Println("  generator -dry-run                           # 只列出将要生成的文件及其状态")
+	fmt.Println("  generator -diff                              # 检查生成结果是否与磁盘上的文件一致")
+	fmt.Println("  generator -include 'server/**' -exclude '**/*_test.go.tpl'  # 按 glob 模式筛选模板")
+	fmt.Println("  generator -prune                             # 生成并删除本次不再生成的过期文件")
+	fmt.Println("  generator vars -set server.port=9090         # 查看每个变量来自哪个文件的哪一行")
+	fmt.Println("  generator -profile prod -profile eu          # 在 base/ 的变量上叠加 prod 和 eu 的变量配置")
 }
 
 // 这些变量会在编译时通过 -ldflags 注入
@@ -146,9 +531,47 @@ func printVersion() {
 func generateQuickStartExample() error {
 	files := map[string]string{
 		".gen_config.yaml": `config:
+  # 相对路径相对于本配置文件所在目录
   template_dir: ".gen_templates"
   variables_dir: ".gen_variables"
-  output_dir: ".gen_output"`,
+  output_dir: ".gen_output"
+  # 额外的变量文件
+  # variable_files:
+  #   - ".gen_variables/example.yaml"
+  # 要跳过的模板文件后缀/路径前缀，多个用逗号分隔
+  # skip_template_suffixes: ".go.tpl.tpl,.vue.tpl"
+  # skip_template_prefixes: "web,server/config"
+  # 合并多个变量文件时列表的合并策略: replace(默认), append, merge-by-key[:字段]
+  # list_merge: replace
+  # list_merge_paths:
+  #   server.routes: "merge-by-key:name"
+  # 从带此前缀的环境变量中读取模板变量，GEN_PROJECT__NAME 对应 project.name
+  # env_prefix: GEN_
+  # 变量校验规则文件，默认使用模板目录中的 .gen_schema.yaml
+  # schema_file: schema.yaml
+  # 叠加变量目录中 profiles/<名称>/ 下的变量文件，后面的覆盖前面的
+  # profiles: [prod]
+  # 问题文件，默认使用模板目录中的 .gen_questions.yaml；save_answers 将回答保存到变量目录的 answers.yaml
+  # questions_file: questions.yaml
+  # save_answers: true
+  # 限制模板函数 file 和 include 只能访问模板目录、变量目录和 sandbox_roots 中的文件
+  # sandbox: true
+  # sandbox_roots: [shared]
+  # 允许匹配的模板写入输出目录之外（输出路径默认不能超出输出目录）
+  # allow_outside_output: ["shared/**"]
+  # 视为机密的变量键名模式，诊断信息和 vars 输出中隐藏其值；变量文件中也可以用 !secret 标记
+  # secret_keys: ["*password*", "*token*"]
+  # 输出路径中的变量（如 __project.name__）无法替换时生成失败
+  # strict_path_variables: true
+  # 不写入内容只包含空白的文件，并删除上次生成且未被修改的同名文件；skip_empty_templates 只对匹配的模板生效
+  # skip_empty: true
+  # skip_empty_templates: ["docs/**"]
+  # 对列表变量中的每一项各生成一个文件，模板中用 .route 访问列表项，输出路径中用 __route.name__
+  # each:
+  #   - pattern: "handlers/**"
+  #     each: routes as route
+  # 不在输出目录中写入生成清单 .gen_manifest.json（-prune 依赖该清单）
+  # disable_manifest: true`,
 		".gen_variables/example.yaml": `greeting: "Hello"
 name: "World"
 # 特殊配置：允许模板中使用未定义的变量
@@ -206,7 +629,8 @@ $config.allowUndefinedVariables: true`,
 	fmt.Println("   - 变量目录: .gen_variables")
 	fmt.Println("   - 输出目录: .gen_output (将在生成时创建)")
 	fmt.Println("2. 您可以直接运行 'generator' 命令来测试，无需额外参数。")
-	fmt.Println("3. 如果您想自定义配置，可以修改 .gen_config.yaml 文件。")
+	fmt.Println("3. 如果您想自定义配置，可以修改 .gen_config.yaml 文件（在工作目录及其上级目录中查找），")
+	fmt.Println("   配置优先级为：默认值 < 配置文件 < 环境变量(GENERATOR_*) < 命令行参数。")
 	fmt.Println("4. 如果您不需要自定义配置，可以安全地删除 .gen_config.yaml 文件。")
 	fmt.Println("5. 你也可以通过命令行使用以下参数进行配置:")
 	fmt.Println("   generator -template <模板目录> -variables <变量目录> -output <输出目录>")
@@ -2,7 +2,6 @@ package main
 
 import (
 	"log"
-	"os"
 	"path/filepath"
 
 	"github.com/clh021/generator/pkg/config"
@@ -67,19 +66,12 @@ func main() {
 	}
 
 	// 写入生成的文件
-	for _, file := range files {
-		// 创建输出目录
-		outputDir := filepath.Dir(file.OutputPath)
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			log.Fatalf("创建输出目录失败: %v", err)
-		}
-
-		// 创建输出文件
-		if err := os.WriteFile(file.OutputPath, []byte(file.Content), 0644); err != nil {
-			log.Fatalf("写入文件失败: %v", err)
-		}
-
-		log.Printf("已写入文件: %s", file.OutputPath)
+	results, err := gen.WriteFiles(files)
+	if err != nil {
+		log.Fatalf("写入失败: %v", err)
+	}
+	for _, result := range results {
+		log.Printf("已写入文件: %s (%s)", result.OutputPath, result.Action)
 	}
 
 	log.Println("生成完成")
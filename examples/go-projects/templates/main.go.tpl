@@ -22,6 +22,8 @@ func main() {
 
 {{- range .routes }}
 func {{ .handler }}(w http.ResponseWriter, r *http.Request) {
+    // gen:keep begin {{ .handler }}
     fmt.Fprintf(w, "This is the {{ .handler }} handler")
+    // gen:keep end
 }
 {{- end }}
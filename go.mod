@@ -3,6 +3,12 @@ module github.com/clh021/generator
 go 1.22.3
 
 require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/bmatcuk/doublestar/v4 v4.8.1
+	github.com/joho/godotenv v1.5.1
 	github.com/pkg/errors v0.9.1
+	golang.org/x/term v0.22.0
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.22.0 // indirect
@@ -41,7 +41,7 @@ func ucfirst(s string) string {
 
 // funcMap 返回模板中可用的函数映射
 func (e *Engine) funcMap() template.FuncMap {
-	return template.FuncMap{
+	return withStringFilters(template.FuncMap{
 		"include": e.createIncludeTemplateFunc(0, []string{}),
 		"dict": func(values ...interface{}) (map[string]interface{}, error) {
 			if len(values)%2 != 0 {
@@ -60,22 +60,31 @@ func (e *Engine) funcMap() template.FuncMap {
 		"currentYear": func() int {
 			return time.Now().Year()
 		},
-		"file": func(filePath string) (string, error) {
-			content, err := os.ReadFile(filepath.Join(e.templateDir, filePath))
-			if err != nil {
-				return "", fmt.Errorf("读取文件失败 %s: %w", filePath, err)
-			}
-			return string(content), nil
-		},
+		"file": e.readFile,
 		"default": func(value, defaultValue interface{}) interface{} {
 			if value == nil || value == "" {
 				return defaultValue
 			}
 			return value
 		},
-		"lcfirst": lcfirst,
-		"ucfirst": ucfirst,
+	})
+}
+
+// readFile 读取相对于模板目录的文件，启用沙箱时只能读取允许的目录中的文件
+func (e *Engine) readFile(filePath string) (string, error) {
+	path := filepath.Join(e.templateDir, filePath)
+	if e.sandbox != nil {
+		resolved, err := e.sandbox.Check("file", path)
+		if err != nil {
+			return "", err
+		}
+		path = resolved
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败 %s: %w", filePath, err)
 	}
+	return string(content), nil
 }
 
 // GetVariables 返回模板引擎中加载的所有变量
@@ -39,6 +39,15 @@ func (e *Engine) createIncludeTemplateFunc(depth int, templateStack []string) fu
 		}
 		tplPath = filepath.Clean(tplPath) // 清理路径
 
+		// 启用沙箱时只能引用允许的目录中的模板
+		if e.sandbox != nil {
+			resolved, err := e.sandbox.Check("include", tplPath)
+			if err != nil {
+				return "", err
+			}
+			tplPath = resolved
+		}
+
 		// 循环引用检测
 		for _, path := range templateStack {
 			if path == tplPath {
@@ -46,26 +55,10 @@ func (e *Engine) createIncludeTemplateFunc(depth int, templateStack []string) fu
 			}
 		}
 
-		// 检查模板是否已经加载
-		tmpl, ok := e.loadedTemplates[tplPath]
-		if !ok {
-			// 读取模板文件
-			content, err := os.ReadFile(tplPath)
-			if err != nil {
-				return "", fmt.Errorf("读取子模板文件 %s 失败: %w", tplPath, err)
-			}
-
-			// 创建模板
-			tmpl = template.New(filepath.Base(tplName)).Funcs(e.funcMap())
-
-			// 解析模板
-			tmpl, err = tmpl.Parse(string(content))
-			if err != nil {
-				return "", fmt.Errorf("解析子模板 %s 失败: %w", tplPath, err)
-			}
-
-			// 缓存模板
-			e.loadedTemplates[tplPath] = tmpl
+		// 加载模板（已加载时使用缓存）
+		tmpl, err := e.loadIncludedTemplate(tplPath, tplName)
+		if err != nil {
+			return "", err
 		}
 
 		// 创建新的模板栈
@@ -75,7 +68,7 @@ func (e *Engine) createIncludeTemplateFunc(depth int, templateStack []string) fu
 		newIncludeFunc := e.createIncludeTemplateFunc(depth+1, newTemplateStack)
 
 		// 创建包含新的 include 函数的 FuncMap
-		funcMapWithNewInclude := template.FuncMap{
+		funcMapWithNewInclude := withStringFilters(template.FuncMap{
 			"include": newIncludeFunc,
 			"dict": func(values ...interface{}) (map[string]interface{}, error) {
 				if len(values)%2 != 0 {
@@ -94,22 +87,14 @@ func (e *Engine) createIncludeTemplateFunc(depth int, templateStack []string) fu
 			"currentYear": func() int {
 				return time.Now().Year()
 			},
-			"file": func(filePath string) (string, error) {
-				content, err := os.ReadFile(filepath.Join(e.templateDir, filePath))
-				if err != nil {
-					return "", fmt.Errorf("读取文件失败 %s: %w", filePath, err)
-				}
-				return string(content), nil
-			},
+			"file": e.readFile,
 			"default": func(value, defaultValue interface{}) interface{} {
 				if value == nil || value == "" {
 					return defaultValue
 				}
 				return value
 			},
-			"lcfirst": lcfirst,
-			"ucfirst": ucfirst,
-		}
+		})
 
 		// 克隆模板并添加新的 FuncMap
 		clonedTmpl, err := tmpl.Clone()
@@ -128,3 +113,30 @@ func (e *Engine) createIncludeTemplateFunc(depth int, templateStack []string) fu
 		return buf.String(), nil
 	}
 }
+
+// loadIncludedTemplate 读取并解析子模板，解析结果按路径缓存，可并发调用
+func (e *Engine) loadIncludedTemplate(tplPath, tplName string) (*template.Template, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// 检查模板是否已经加载
+	if tmpl, ok := e.loadedTemplates[tplPath]; ok {
+		return tmpl, nil
+	}
+
+	// 读取模板文件
+	content, err := os.ReadFile(tplPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取子模板文件 %s 失败: %w", tplPath, err)
+	}
+
+	// 创建并解析模板
+	tmpl, err := template.New(filepath.Base(tplName)).Funcs(e.funcMap()).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("解析子模板 %s 失败: %w", tplPath, err)
+	}
+
+	// 缓存模板
+	e.loadedTemplates[tplPath] = tmpl
+	return tmpl, nil
+}
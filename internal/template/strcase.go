@@ -0,0 +1,114 @@
+package template
+
+import (
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// StringFilters 模板函数和输出路径占位符（如 __entity.name|snake__）共用的字符串转换函数，
+// 保证文件名与文件内容中的写法一致
+var StringFilters = map[string]func(string) string{
+	"snake":   snake,
+	"kebab":   kebab,
+	"pascal":  pascal,
+	"camel":   camel,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"plural":  plural,
+	"lcfirst": lcfirst,
+	"ucfirst": ucfirst,
+}
+
+// withStringFilters 将 StringFilters 加入函数映射
+func withStringFilters(funcs template.FuncMap) template.FuncMap {
+	for name, f := range StringFilters {
+		funcs[name] = f
+	}
+	return funcs
+}
+
+// splitWords 将字符串拆分为单词，在非字母数字的分隔符、小写到大写的位置和缩写词结尾处拆分，数字归入前一个单词，
+// 如 userProfile、user-profile -> user Profile，HTTPServer -> HTTP Server，oauth2Client -> oauth2 Client
+func splitWords(s string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 {
+			prev := word[len(word)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// snake 转换为下划线命名，如 UserProfile -> user_profile
+func snake(s string) string {
+	return strings.ToLower(strings.Join(splitWords(s), "_"))
+}
+
+// kebab 转换为短横线命名，如 UserProfile -> user-profile
+func kebab(s string) string {
+	return strings.ToLower(strings.Join(splitWords(s), "-"))
+}
+
+// pascal 转换为大驼峰命名，如 user_profile -> UserProfile
+func pascal(s string) string {
+	var sb strings.Builder
+	for _, word := range splitWords(s) {
+		sb.WriteString(ucfirst(strings.ToLower(word)))
+	}
+	return sb.String()
+}
+
+// camel 转换为小驼峰命名，如 user_profile -> userProfile
+func camel(s string) string {
+	return lcfirst(pascal(s))
+}
+
+// plural 返回最后一个单词的英文复数形式，其余部分不变，
+// 如 UserProfile -> UserProfiles，category -> categories，box -> boxes
+func plural(s string) string {
+	if s == "" {
+		return ""
+	}
+	runes := []rune(s)
+	last := runes[len(runes)-1]
+	if !unicode.IsLetter(last) {
+		return s
+	}
+	// 全大写的字符串使用大写的后缀，如 USER -> USERS，userID -> userIDs
+	suffix := func(lower string) string {
+		if strings.ToUpper(s) == s {
+			return strings.ToUpper(lower)
+		}
+		return lower
+	}
+
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasSuffix(lower, "y") && len(runes) > 1 && !strings.ContainsRune("aeiou", unicode.ToLower(runes[len(runes)-2])):
+		return string(runes[:len(runes)-1]) + suffix("ies")
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return s + suffix("es")
+	}
+	return s + suffix("s")
+}
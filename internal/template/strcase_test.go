@@ -0,0 +1,48 @@
+package template
+
+import (
+	"testing"
+)
+
+func TestStringFilters(t *testing.T) {
+	tests := []struct {
+		input  string
+		snake  string
+		kebab  string
+		pascal string
+		camel  string
+		plural string
+	}{
+		{"UserProfile", "user_profile", "user-profile", "UserProfile", "userProfile", "UserProfiles"},
+		{"user_profile", "user_profile", "user-profile", "UserProfile", "userProfile", "user_profiles"},
+		{"user-profile", "user_profile", "user-profile", "UserProfile", "userProfile", "user-profiles"},
+		{"HTTPServer", "http_server", "http-server", "HttpServer", "httpServer", "HTTPServers"},
+		{"userID", "user_id", "user-id", "UserId", "userId", "userIDs"},
+		{"oauth2Client", "oauth2_client", "oauth2-client", "Oauth2Client", "oauth2Client", "oauth2Clients"},
+		{"order item", "order_item", "order-item", "OrderItem", "orderItem", "order items"},
+		{"category", "category", "category", "Category", "category", "categories"},
+		{"box", "box", "box", "Box", "box", "boxes"},
+		{"USER", "user", "user", "User", "user", "USERS"},
+		{"day", "day", "day", "Day", "day", "days"},
+		{"", "", "", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			want := map[string]string{"snake": tt.snake, "kebab": tt.kebab, "pascal": tt.pascal, "camel": tt.camel, "plural": tt.plural}
+			for name, w := range want {
+				if got := StringFilters[name](tt.input); got != w {
+					t.Errorf("%s(%q) = %q, want %q", name, tt.input, got, w)
+				}
+			}
+		})
+	}
+
+	// 模板函数与路径过滤器是同一实现
+	funcs := New("", "", "").funcMap()
+	for name := range StringFilters {
+		if _, ok := funcs[name].(func(string) string); !ok {
+			t.Errorf("funcMap() is missing filter %s", name)
+		}
+	}
+}
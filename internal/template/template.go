@@ -6,17 +6,31 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"text/template"
 
-	"gopkg.in/yaml.v3"
+	"github.com/clh021/generator/pkg/sandbox"
+	"github.com/clh021/generator/pkg/variables"
 )
 
+// Engine 模板引擎
+// 加载变量后，GenerateContent 可以被多个 goroutine 并发调用
 type Engine struct {
-	templateDir     string
-	variablesDir    string
-	outputDir       string
-	vars            map[string]interface{}
+	templateDir  string
+	variablesDir string
+	outputDir    string
+	vars         map[string]interface{}
+	// 每个变量值的来源
+	provenance      *variables.Provenance
 	loadedTemplates map[string]*template.Template
+	// 保护 loadedTemplates
+	mu sync.Mutex
+	// 合并多个变量文件时的选项
+	mergeOptions variables.MergeOptions
+	// 不为 nil 时 file 和 include 只能访问其中允许的目录
+	sandbox *sandbox.Sandbox
+	// 诊断信息中需要隐藏的机密变量
+	secrets *variables.Secrets
 }
 
 func New(templateDir, variablesDir, outputDir string) *Engine {
@@ -25,44 +39,114 @@ func New(templateDir, variablesDir, outputDir string) *Engine {
 		variablesDir:    variablesDir,
 		outputDir:       outputDir,
 		vars:            make(map[string]interface{}),
+		provenance:      variables.NewProvenance(),
 		loadedTemplates: make(map[string]*template.Template),
 	}
 }
 
+// WithMergeOptions 设置合并多个变量文件时的选项
+func (e *Engine) WithMergeOptions(opts variables.MergeOptions) *Engine {
+	e.mergeOptions = opts
+	return e
+}
+
+// WithSandbox 限制 file 和 include 函数只能访问沙箱允许的目录，nil 表示不限制
+func (e *Engine) WithSandbox(s *sandbox.Sandbox) *Engine {
+	e.sandbox = s
+	return e
+}
+
+// WithSecrets 设置机密变量，模板执行失败时输出的变量和错误信息会隐藏它们的值
+func (e *Engine) WithSecrets(s *variables.Secrets) *Engine {
+	e.secrets = s
+	return e
+}
+
+// Scope 返回一个新的引擎，其变量为已加载的变量加上 data 中的顶层变量，同名时 data 优先
+// 新引擎与原引擎共享配置、变量来源和机密变量，原引擎不受影响，用于按列表逐项渲染同一模板
+func (e *Engine) Scope(data map[string]interface{}) *Engine {
+	vars := make(map[string]interface{}, len(e.vars)+len(data))
+	for k, v := range e.vars {
+		vars[k] = v
+	}
+	for k, v := range data {
+		vars[k] = v
+	}
+	return &Engine{
+		templateDir:     e.templateDir,
+		variablesDir:    e.variablesDir,
+		outputDir:       e.outputDir,
+		vars:            vars,
+		provenance:      e.provenance,
+		loadedTemplates: make(map[string]*template.Template),
+		mergeOptions:    e.mergeOptions,
+		sandbox:         e.sandbox,
+		secrets:         e.secrets,
+	}
+}
+
+// LoadVariables 按顺序加载变量文件，后面的文件深度合并到前面的结果中
+// 文件格式按扩展名选择，也可以用 @格式 后缀指定，见 variables.DecodeFile
+// 同时记录每个变量值来自哪个文件的哪一行，见 Provenance
 func (e *Engine) LoadVariables(variableFiles []string) error {
 	for _, path := range variableFiles {
-		data, err := os.ReadFile(path)
+		vars, provenance, err := variables.LoadFile(path)
 		if err != nil {
-			return fmt.Errorf("读取变量文件 %s 失败: %w", path, err)
+			return err
 		}
 
-		var vars map[string]interface{}
-		if err := yaml.Unmarshal(data, &vars); err != nil {
-			return fmt.Errorf("解析变量文件 %s 失败: %w", path, err)
-		}
+		// 深度合并变量
+		e.vars = variables.MergeWithProvenance(e.vars, vars, e.provenance, provenance, e.mergeOptions)
+	}
+
+	return nil
+}
+
+// MergeVariables 将 vars 深度合并到已加载的变量上，provenance 为 vars 中值的来源，可以为 nil
+func (e *Engine) MergeVariables(vars map[string]interface{}, provenance *variables.Provenance) {
+	e.vars = variables.MergeWithProvenance(e.vars, vars, e.provenance, provenance, e.mergeOptions)
+}
 
-		// 合并变量
-		for k, v := range vars {
-			// 如果是嵌套的映射，需要特殊处理
-			if existingVal, ok := e.vars[k]; ok {
-				if existingMap, ok := existingVal.(map[string]interface{}); ok {
-					if newMap, ok := v.(map[string]interface{}); ok {
-						// 合并嵌套映射
-						for nk, nv := range newMap {
-							existingMap[nk] = nv
-						}
-						continue
-					}
-				}
-			}
-			// 对于非嵌套映射或新键，直接赋值
-			e.vars[k] = v
+// ApplyOverrides 将命令行变量覆盖深度合并到已加载的变量上，来源记录为 "-set 路径"
+func (e *Engine) ApplyOverrides(overrides []variables.Override) error {
+	if err := variables.ApplyOverrides(e.vars, overrides, e.mergeOptions); err != nil {
+		return err
+	}
+	for _, o := range overrides {
+		if err := e.provenance.Record(o.Path, o.Value, variables.Origin{File: "-set " + o.Path}); err != nil {
+			return err
 		}
 	}
-
 	return nil
 }
 
+// ResolveVariables 展开变量中的 ${路径} 引用并计算 computed 中的变量，见 variables.Resolve
+// 应在所有变量加载和合并完成后调用一次，之后渲染的模板看到的都是最终的值
+func (e *Engine) ResolveVariables() error {
+	return variables.Resolve(e.vars, e.funcMap(), e.provenance)
+}
+
+// FuncMap 返回模板中可用的函数
+func (e *Engine) FuncMap() template.FuncMap {
+	return e.funcMap()
+}
+
+// RecordOrigin 记录在引擎外修改的变量路径的来源，如校验规则中的默认值
+func (e *Engine) RecordOrigin(path string, origin variables.Origin) error {
+	value, _ := variables.Get(e.vars, path)
+	return e.provenance.Record(path, value, origin)
+}
+
+// Provenance 返回已加载变量的来源记录
+func (e *Engine) Provenance() *variables.Provenance {
+	return e.provenance
+}
+
+// Origin 返回变量路径（如 server.port、routes[0].name）的值的来源
+func (e *Engine) Origin(path string) (variables.Origin, bool) {
+	return e.provenance.Lookup(path)
+}
+
 // GenerateContent 生成模板内容但不写入文件
 func (e *Engine) GenerateContent(tplPath, outputPath string) (string, error) {
 	// 读取模板文件
@@ -104,12 +188,43 @@ func (e *Engine) GenerateContent(tplPath, outputPath string) (string, error) {
 		log.Printf(" - 正在执行模板 %s", tplPath)
 		log.Printf(" - 目标输出文件: %s", outputPath)
 		log.Printf("传递给模板的变量:")
-		for k, v := range varsWithTemplatePath {
+		for k, v := range e.secrets.Redact(varsWithTemplatePath) {
 			log.Printf("  %s = %v", k, v)
 		}
 
+		// 模板函数返回的错误中可能包含变量的值
+		if msg := e.secrets.RedactText(e.vars, err.Error()); msg != err.Error() {
+			return "", fmt.Errorf("执行模板失败 (template: %s): %s", tplPath, msg)
+		}
 		return "", fmt.Errorf("执行模板失败 (template: %s): %w", tplPath, err)
 	}
 
 	return result.String(), nil
 }
+
+// EvalCondition 按已加载的变量对条件表达式求值，写法与 {{ if }} 中相同，如 .features.auth、and .a (not .b)
+// 结果按 {{ if }} 的规则判断真假：false、0、nil、空字符串和空的映射或列表为假
+func (e *Engine) EvalCondition(expr string) (bool, error) {
+	if strings.Contains(expr, "{{") || strings.Contains(expr, "}}") {
+		return false, fmt.Errorf("条件中不能包含 {{ 或 }}: %s", expr)
+	}
+	tmpl := template.New("condition").Funcs(e.funcMap())
+	if allowUndefined, ok := e.vars["$config.allowUndefinedVariables"].(bool); ok && allowUndefined {
+		tmpl = tmpl.Option("missingkey=zero")
+	} else {
+		tmpl = tmpl.Option("missingkey=error")
+	}
+	tmpl, err := tmpl.Parse("{{ if " + expr + " }}true{{ end }}")
+	if err != nil {
+		return false, fmt.Errorf("解析条件失败: %w", err)
+	}
+
+	var result strings.Builder
+	if err := tmpl.Execute(&result, e.vars); err != nil {
+		if msg := e.secrets.RedactText(e.vars, err.Error()); msg != err.Error() {
+			return false, fmt.Errorf("条件求值失败: %s", msg)
+		}
+		return false, fmt.Errorf("条件求值失败: %w", err)
+	}
+	return result.String() == "true", nil
+}
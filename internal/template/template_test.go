@@ -1,12 +1,17 @@
 package template
 
 import (
+	"errors"
+	"log"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/clh021/generator/pkg/sandbox"
+	"github.com/clh021/generator/pkg/variables"
 )
 
 func TestNew(t *testing.T) {
@@ -119,6 +124,68 @@ func TestGenerateContent(t *testing.T) {
 	}
 }
 
+func TestScope(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "template_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templatePath := filepath.Join(tempDir, "test.tpl")
+	if err := os.WriteFile(templatePath, []byte("{{.Greeting}}, {{.Name}}!"), 0644); err != nil {
+		t.Fatalf("Failed to write template file: %v", err)
+	}
+
+	e := New(tempDir, "/tmp/config", tempDir)
+	e.vars["Greeting"] = "Hello"
+	e.vars["Name"] = "World"
+
+	scoped := e.Scope(map[string]interface{}{"Name": "Gopher"})
+	content, err := scoped.GenerateContent(templatePath, filepath.Join(tempDir, "result.txt"))
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if content != "Hello, Gopher!" {
+		t.Errorf("Expected content to be 'Hello, Gopher!', got '%s'", content)
+	}
+	// 原引擎的变量不受影响
+	if e.vars["Name"] != "World" {
+		t.Errorf("Expected Name to be World, got %v", e.vars["Name"])
+	}
+}
+
+func TestEvalCondition(t *testing.T) {
+	e := New("/tmp/template", "/tmp/variables", "/tmp/output")
+	e.vars["features"] = map[string]interface{}{"auth": true, "docker": false}
+	e.vars["routes"] = []interface{}{}
+	e.vars["name"] = "UserProfile"
+
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{expr: ".features.auth", want: true},
+		{expr: ".features.docker", want: false},
+		{expr: "and .features.auth (not .features.docker)", want: true},
+		{expr: ".routes", want: false},
+		{expr: `eq (snake .name) "user_profile"`, want: true},
+		{expr: ".features.sso", wantErr: true},
+		{expr: "", wantErr: true},
+		{expr: ".features.auth }}x{{ if true", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := e.EvalCondition(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("EvalCondition(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("EvalCondition(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
 func TestGenerateContentWithFunctions(t *testing.T) {
 	// 创建临时目录
 	tempDir, err := os.MkdirTemp("", "template_functions_test")
@@ -269,3 +336,125 @@ func TestFuncMap(t *testing.T) {
 		t.Errorf("default function returned %v, expected 'defaultValue'", defaultResult)
 	}
 }
+
+func TestGenerateContentWithSandbox(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "template_sandbox_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateDir := filepath.Join(tempDir, "templates")
+	shared := filepath.Join(tempDir, "shared")
+	files := map[string]string{
+		filepath.Join(templateDir, "license.txt"):  "MIT",
+		filepath.Join(templateDir, "child.tpl"):    "child",
+		filepath.Join(shared, "header.tpl"):        "header",
+		filepath.Join(tempDir, "secret", "id_rsa"): "secret",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(tempDir, "secret"), filepath.Join(templateDir, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	s, err := sandbox.New(templateDir, shared)
+	if err != nil {
+		t.Fatalf("sandbox.New() error = %v", err)
+	}
+	e := New(templateDir, "", filepath.Join(tempDir, "output")).WithSandbox(s)
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		denied  bool
+	}{
+		{name: "file in template dir", content: `{{ file "license.txt" }}`, want: "MIT"},
+		{name: "include relative", content: `{{ include "child.tpl" . }}`, want: "child"},
+		{name: "include extra root", content: `{{ include "` + filepath.Join(shared, "header.tpl") + `" . }}`, want: "header"},
+		{name: "file traversal", content: `{{ file "../secret/id_rsa" }}`, denied: true},
+		{name: "file through symlink", content: `{{ file "link/id_rsa" }}`, denied: true},
+		{name: "include absolute", content: `{{ include "` + filepath.Join(tempDir, "secret", "id_rsa") + `" . }}`, denied: true},
+		{name: "include traversal", content: `{{ include "../secret/id_rsa" . }}`, denied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templatePath := filepath.Join(templateDir, "main.tpl")
+			if err := os.WriteFile(templatePath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write template file: %v", err)
+			}
+			content, err := e.GenerateContent(templatePath, "")
+			if tt.denied {
+				var sandboxErr *sandbox.Error
+				if !errors.As(err, &sandboxErr) {
+					t.Fatalf("GenerateContent() error = %v, want *sandbox.Error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateContent() error = %v", err)
+			}
+			if content != tt.want {
+				t.Errorf("GenerateContent() = %q, want %q", content, tt.want)
+			}
+		})
+	}
+
+	// 未启用沙箱时不限制
+	content, err := New(templateDir, "", "").GenerateContent(filepath.Join(templateDir, "main.tpl"), "")
+	if err != nil || content != "secret" {
+		t.Errorf("GenerateContent() without sandbox = %q, %v, want secret", content, err)
+	}
+}
+
+func TestGenerateContentRedactsSecrets(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "secrets_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templatePath := filepath.Join(tempDir, "main.tpl")
+	if err := os.WriteFile(templatePath, []byte(`{{ file .db.password }}`), 0644); err != nil {
+		t.Fatalf("Failed to write template file: %v", err)
+	}
+	secrets, err := variables.NewSecrets(variables.DefaultSecretKeys)
+	if err != nil {
+		t.Fatalf("NewSecrets() error = %v", err)
+	}
+	e := New(tempDir, "", "").WithSecrets(secrets)
+	e.MergeVariables(map[string]interface{}{
+		"db": map[string]interface{}{"host": "localhost", "password": "hunter22"},
+	}, nil)
+
+	var buf strings.Builder
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	// 失败时输出的变量和错误信息都不包含机密的值
+	_, err = e.GenerateContent(templatePath, "")
+	if err == nil {
+		t.Fatal("GenerateContent() expected error, got nil")
+	}
+	if strings.Contains(err.Error(), "hunter22") || !strings.Contains(err.Error(), variables.RedactedValue) {
+		t.Errorf("GenerateContent() error = %v, want the password redacted", err)
+	}
+	if strings.Contains(buf.String(), "hunter22") || !strings.Contains(buf.String(), "map[host:localhost password:******]") {
+		t.Errorf("log output = %s, want the password redacted", buf.String())
+	}
+	// 模板得到的仍然是真实的值
+	if err := os.WriteFile(templatePath, []byte(`{{ .db.password }}`), 0644); err != nil {
+		t.Fatalf("Failed to write template file: %v", err)
+	}
+	if content, err := e.GenerateContent(templatePath, ""); err != nil || content != "hunter22" {
+		t.Errorf("GenerateContent() = %q, %v, want hunter22", content, err)
+	}
+}
@@ -1,10 +1,425 @@
 package config
 
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName 项目配置文件名
+const ConfigFileName = ".gen_config.yaml"
+
+// EnvPrefix 配置相关环境变量的前缀
+const EnvPrefix = "GENERATOR_"
+
+// 默认目录
+const (
+	DefaultTemplateDir  = ".gen_templates"
+	DefaultVariablesDir = ".gen_variables"
+	DefaultOutputDir    = ".gen_output"
+)
+
 type Config struct {
-	TemplateDir          string
-	VariablesDir         string
-	OutputDir            string
-	VariableFiles        []string
-	SkipTemplateSuffixes string // 要跳过的模板文件后缀，多个后缀用逗号分隔，完整路径(path)进行匹配
-	SkipTemplatePrefixes string // 要跳过的模板路径前缀，多个前缀用逗号分隔，相对于模板目录，不要前置/符号
+	TemplateDir          string            `yaml:"template_dir"`
+	VariablesDir         string            `yaml:"variables_dir"`
+	OutputDir            string            `yaml:"output_dir"`
+	VariableFiles        []string          `yaml:"variable_files"`
+	Profiles             []string          `yaml:"profiles"`               // 按顺序叠加的变量配置，对应变量目录中的 profiles/<名称>/，后面的覆盖前面的
+	SkipTemplateSuffixes string            `yaml:"skip_template_suffixes"` // 要跳过的模板文件后缀，多个后缀用逗号分隔，完整路径(path)进行匹配
+	SkipTemplatePrefixes string            `yaml:"skip_template_prefixes"` // 要跳过的模板路径前缀，多个前缀用逗号分隔，相对于模板目录，不要前置/符号
+	Include              []string          `yaml:"include"`                // 包含模式（glob，支持 **），匹配模板相对路径，非空时只生成匹配的模板
+	Exclude              []string          `yaml:"exclude"`                // 排除模式（glob，支持 **），匹配模板相对路径
+	WritePolicy          string            `yaml:"write_policy"`           // 默认写入策略: overwrite, skip-if-exists, fail-if-exists, write-if-changed, backup, backup-timestamp
+	WritePolicies        []WritePolicyRule `yaml:"write_policies"`         // 按模板指定的写入策略，第一个匹配的规则生效
+	AllowOrphanedRegions bool              `yaml:"allow_orphaned_regions"` // 现有文件中的保留区域在模板中不存在时只报告而不报错
+	DisableManifest      bool              `yaml:"disable_manifest"`       // 不在输出目录中写入生成清单 .gen_manifest.json
+	Jobs                 int               `yaml:"jobs"`                   // 并发渲染模板的数量，0 表示使用 CPU 核数
+	ListMerge            string            `yaml:"list_merge"`             // 合并多个变量文件时列表的默认合并策略: replace(默认), append, merge-by-key[:字段]
+	ListMergePaths       map[string]string `yaml:"list_merge_paths"`       // 按变量路径（点分隔）指定的列表合并策略
+	VariableEnvPrefix    string            `yaml:"env_prefix"`             // 从带此前缀的环境变量中读取模板变量，如 GEN_，GEN_PROJECT__NAME 对应 project.name
+	SchemaFile           string            `yaml:"schema_file"`            // 变量校验规则文件，默认使用模板目录中的 .gen_schema.yaml/.yml/.json
+	QuestionsFile        string            `yaml:"questions_file"`         // 声明需要交互输入的变量的问题文件，默认使用模板目录中的 .gen_questions.yaml
+	SaveAnswers          bool              `yaml:"save_answers"`           // 将交互输入的回答保存到变量目录的 answers.yaml 中，下次生成时直接使用
+	Sandbox              bool              `yaml:"sandbox"`                // 限制模板函数 file 和 include 只能访问模板目录、变量目录和 sandbox_roots 中的文件
+	SandboxRoots         []string          `yaml:"sandbox_roots"`          // 启用沙箱时额外允许访问的目录
+	AllowOutsideOutput   []string          `yaml:"allow_outside_output"`   // 允许输出路径位于输出目录之外的模板（glob，支持 **），匹配模板相对路径
+	SecretKeys           []string          `yaml:"secret_keys"`            // 视为机密的变量键名模式（不区分大小写），未设置时使用 variables.DefaultSecretKeys
+	StrictPathVariables  bool              `yaml:"strict_path_variables"`  // 输出路径中的变量（__name__）无法替换时生成失败，而不是警告并保留原始字符串
+	Each                 []EachRule        `yaml:"each"`                   // 按列表变量逐项生成的模板，每个列表项生成一个文件，第一个匹配的规则生效
+	SkipEmpty            bool              `yaml:"skip_empty"`             // 不写入内容只包含空白的文件，并删除上次生成、之后未被修改的同名文件
+	SkipEmptyTemplates   []string          `yaml:"skip_empty_templates"`   // 对匹配的模板（glob，支持 **）启用 skip_empty，匹配模板相对路径；skip_empty 为 true 时对所有模板生效
+
+	// 显式设置的配置项（配置文件中的名称），Merge 时即使为 false 或 0 也会覆盖
+	explicit map[string]bool
+}
+
+// WritePolicyRule 为匹配的模板指定写入策略
+type WritePolicyRule struct {
+	Pattern string `yaml:"pattern"` // glob 模式（支持 **），匹配模板相对路径
+	Policy  string `yaml:"policy"`  // 写入策略
+}
+
+// EachRule 让匹配的模板对列表变量中的每一项各生成一个文件
+type EachRule struct {
+	Pattern string `yaml:"pattern"` // glob 模式（支持 **），匹配模板相对路径
+	Each    string `yaml:"each"`    // 列表变量及列表项的名称，格式为 "<变量路径> [as <名称>]"，如 routes as route
+}
+
+// fileConfig 对应配置文件的结构，所有配置项位于 config 节点下
+type fileConfig struct {
+	Config Config `yaml:"config"`
+}
+
+// fileKeys 用于获取配置文件中出现的配置项
+type fileKeys struct {
+	Config map[string]yaml.Node `yaml:"config"`
+}
+
+// Default 返回使用默认目录的配置
+func Default() *Config {
+	return &Config{
+		TemplateDir:   DefaultTemplateDir,
+		VariablesDir:  DefaultVariablesDir,
+		OutputDir:     DefaultOutputDir,
+		VariableFiles: []string{},
+	}
+}
+
+// Load 按 默认值 < 配置文件 < 环境变量 的优先级构建配置
+// configFile 为空时从 workDir 开始向上查找 .gen_config.yaml
+// 返回配置以及实际使用的配置文件路径（未找到时为空）
+// 命令行参数的优先级最高，由调用方在此之后覆盖
+func Load(workDir, configFile string) (*Config, string, error) {
+	cfg := Default().Resolve(workDir)
+
+	if configFile == "" {
+		found, err := FindConfigFile(workDir)
+		if err != nil {
+			return nil, "", err
+		}
+		configFile = found
+	}
+
+	if configFile != "" {
+		if err := cfg.ApplyFile(configFile); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := cfg.ApplyEnv(workDir, os.LookupEnv); err != nil {
+		return nil, "", err
+	}
+
+	return cfg, configFile, nil
+}
+
+// FindConfigFile 从 startDir 开始逐级向上查找配置文件
+// 未找到时返回空字符串
+func FindConfigFile(startDir string) (string, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", errors.Wrapf(err, "无法获取目录的绝对路径: %s", startDir)
+	}
+
+	for {
+		path := filepath.Join(dir, ConfigFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// ApplyFile 读取配置文件并覆盖文件中出现的配置项
+// 文件中的相对路径相对于配置文件所在目录
+func (c *Config) ApplyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "读取配置文件失败: %s", path)
+	}
+
+	var fc fileConfig
+	if err := yaml.Unmarshal(data, &fc); err != nil {
+		return errors.Wrapf(err, "解析配置文件失败: %s", path)
+	}
+	var keys fileKeys
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return errors.Wrapf(err, "解析配置文件失败: %s", path)
+	}
+	for key := range keys.Config {
+		fc.Config.MarkSet(key)
+	}
+
+	c.Merge(fc.Config.Resolve(filepath.Dir(path)))
+	return nil
+}
+
+// ApplyEnv 使用环境变量覆盖配置项，环境变量中的相对路径相对于 workDir
+//
+//	GENERATOR_TEMPLATE_DIR, GENERATOR_VARIABLES_DIR, GENERATOR_OUTPUT_DIR
+//	GENERATOR_VARIABLE_FILES（逗号分隔）
+//	GENERATOR_SKIP_SUFFIXES, GENERATOR_SKIP_PREFIXES
+//	GENERATOR_INCLUDE, GENERATOR_EXCLUDE（逗号分隔）
+//	GENERATOR_WRITE_POLICY, GENERATOR_ALLOW_ORPHANED_REGIONS
+//	GENERATOR_DISABLE_MANIFEST, GENERATOR_JOBS, GENERATOR_LIST_MERGE
+//	GENERATOR_ENV_PREFIX, GENERATOR_SCHEMA_FILE
+//	GENERATOR_QUESTIONS_FILE, GENERATOR_SAVE_ANSWERS
+//	GENERATOR_PROFILES（逗号分隔）
+//	GENERATOR_SANDBOX, GENERATOR_SANDBOX_ROOTS（逗号分隔）
+//	GENERATOR_ALLOW_OUTSIDE_OUTPUT（逗号分隔）
+//	GENERATOR_SECRET_KEYS（逗号分隔）
+//	GENERATOR_STRICT_PATH_VARIABLES
+//	GENERATOR_SKIP_EMPTY, GENERATOR_SKIP_EMPTY_TEMPLATES（逗号分隔）
+func (c *Config) ApplyEnv(workDir string, lookup func(string) (string, bool)) error {
+	var env Config
+
+	if v, ok := lookup(EnvPrefix + "TEMPLATE_DIR"); ok {
+		env.TemplateDir = v
+	}
+	if v, ok := lookup(EnvPrefix + "VARIABLES_DIR"); ok {
+		env.VariablesDir = v
+	}
+	if v, ok := lookup(EnvPrefix + "OUTPUT_DIR"); ok {
+		env.OutputDir = v
+	}
+	if v, ok := lookup(EnvPrefix + "VARIABLE_FILES"); ok {
+		env.VariableFiles = SplitList(v)
+	}
+	if v, ok := lookup(EnvPrefix + "PROFILES"); ok {
+		env.Profiles = SplitList(v)
+	}
+	if v, ok := lookup(EnvPrefix + "SKIP_SUFFIXES"); ok {
+		env.SkipTemplateSuffixes = v
+	}
+	if v, ok := lookup(EnvPrefix + "SKIP_PREFIXES"); ok {
+		env.SkipTemplatePrefixes = v
+	}
+	if v, ok := lookup(EnvPrefix + "INCLUDE"); ok {
+		env.Include = SplitList(v)
+	}
+	if v, ok := lookup(EnvPrefix + "EXCLUDE"); ok {
+		env.Exclude = SplitList(v)
+	}
+	if v, ok := lookup(EnvPrefix + "WRITE_POLICY"); ok {
+		env.WritePolicy = v
+	}
+	if err := env.lookupBool(lookup, "ALLOW_ORPHANED_REGIONS", &env.AllowOrphanedRegions); err != nil {
+		return err
+	}
+	if err := env.lookupBool(lookup, "DISABLE_MANIFEST", &env.DisableManifest); err != nil {
+		return err
+	}
+	if v, ok := lookup(EnvPrefix + "LIST_MERGE"); ok {
+		env.ListMerge = v
+	}
+	if v, ok := lookup(EnvPrefix + "SCHEMA_FILE"); ok {
+		env.SchemaFile = v
+	}
+	if v, ok := lookup(EnvPrefix + "ENV_PREFIX"); ok {
+		env.VariableEnvPrefix = v
+	}
+	if v, ok := lookup(EnvPrefix + "QUESTIONS_FILE"); ok {
+		env.QuestionsFile = v
+	}
+	if err := env.lookupBool(lookup, "SAVE_ANSWERS", &env.SaveAnswers); err != nil {
+		return err
+	}
+	if err := env.lookupBool(lookup, "SANDBOX", &env.Sandbox); err != nil {
+		return err
+	}
+	if v, ok := lookup(EnvPrefix + "SANDBOX_ROOTS"); ok {
+		env.SandboxRoots = SplitList(v)
+	}
+	if v, ok := lookup(EnvPrefix + "ALLOW_OUTSIDE_OUTPUT"); ok {
+		env.AllowOutsideOutput = SplitList(v)
+	}
+	if v, ok := lookup(EnvPrefix + "SECRET_KEYS"); ok {
+		env.SecretKeys = SplitList(v)
+	}
+	if err := env.lookupBool(lookup, "STRICT_PATH_VARIABLES", &env.StrictPathVariables); err != nil {
+		return err
+	}
+	if err := env.lookupBool(lookup, "SKIP_EMPTY", &env.SkipEmpty); err != nil {
+		return err
+	}
+	if v, ok := lookup(EnvPrefix + "SKIP_EMPTY_TEMPLATES"); ok {
+		env.SkipEmptyTemplates = SplitList(v)
+	}
+	if v, ok := lookup(EnvPrefix + "JOBS"); ok {
+		jobs, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.Wrapf(err, "无效的环境变量 %sJOBS: %s", EnvPrefix, v)
+		}
+		env.Jobs = jobs
+		env.MarkSet("jobs")
+	}
+
+	c.Merge(env.Resolve(workDir))
+	return nil
+}
+
+// Resolve 将配置中的相对路径转换为相对于 baseDir 的路径，返回配置自身
+func (c *Config) Resolve(baseDir string) *Config {
+	c.TemplateDir = resolvePath(baseDir, c.TemplateDir)
+	c.VariablesDir = resolvePath(baseDir, c.VariablesDir)
+	c.OutputDir = resolvePath(baseDir, c.OutputDir)
+	for i, file := range c.VariableFiles {
+		c.VariableFiles[i] = resolvePath(baseDir, file)
+	}
+	c.SchemaFile = resolvePath(baseDir, c.SchemaFile)
+	c.QuestionsFile = resolvePath(baseDir, c.QuestionsFile)
+	for i, root := range c.SandboxRoots {
+		c.SandboxRoots[i] = resolvePath(baseDir, root)
+	}
+	return c
+}
+
+// MarkSet 将配置项（配置文件中的名称，如 sandbox、jobs）标记为显式设置，返回配置自身
+// 用于在 Merge 时让 false 或 0 覆盖较低优先级来源中的值
+func (c *Config) MarkSet(keys ...string) *Config {
+	if c.explicit == nil {
+		c.explicit = make(map[string]bool)
+	}
+	for _, key := range keys {
+		c.explicit[key] = true
+	}
+	return c
+}
+
+// isSet 判断配置项是否已设置：值非零，或者通过 MarkSet 显式设置
+func (c *Config) isSet(key string, nonZero bool) bool {
+	return nonZero || c.explicit[key]
+}
+
+// Merge 使用 other 中已设置（非零值或通过 MarkSet 显式设置）的配置项覆盖当前配置
+func (c *Config) Merge(other *Config) {
+	if other.TemplateDir != "" {
+		c.TemplateDir = other.TemplateDir
+	}
+	if other.VariablesDir != "" {
+		c.VariablesDir = other.VariablesDir
+	}
+	if other.OutputDir != "" {
+		c.OutputDir = other.OutputDir
+	}
+	if other.VariableFiles != nil {
+		c.VariableFiles = other.VariableFiles
+	}
+	if other.Profiles != nil {
+		c.Profiles = other.Profiles
+	}
+	if other.SkipTemplateSuffixes != "" {
+		c.SkipTemplateSuffixes = other.SkipTemplateSuffixes
+	}
+	if other.SkipTemplatePrefixes != "" {
+		c.SkipTemplatePrefixes = other.SkipTemplatePrefixes
+	}
+	if other.Include != nil {
+		c.Include = other.Include
+	}
+	if other.Exclude != nil {
+		c.Exclude = other.Exclude
+	}
+	if other.WritePolicy != "" {
+		c.WritePolicy = other.WritePolicy
+	}
+	if other.WritePolicies != nil {
+		c.WritePolicies = other.WritePolicies
+	}
+	if other.isSet("allow_orphaned_regions", other.AllowOrphanedRegions) {
+		c.AllowOrphanedRegions = other.AllowOrphanedRegions
+	}
+	if other.isSet("disable_manifest", other.DisableManifest) {
+		c.DisableManifest = other.DisableManifest
+	}
+	if other.isSet("jobs", other.Jobs != 0) {
+		c.Jobs = other.Jobs
+	}
+	if other.ListMerge != "" {
+		c.ListMerge = other.ListMerge
+	}
+	if other.ListMergePaths != nil {
+		c.ListMergePaths = other.ListMergePaths
+	}
+	if other.SchemaFile != "" {
+		c.SchemaFile = other.SchemaFile
+	}
+	if other.VariableEnvPrefix != "" {
+		c.VariableEnvPrefix = other.VariableEnvPrefix
+	}
+	if other.QuestionsFile != "" {
+		c.QuestionsFile = other.QuestionsFile
+	}
+	if other.isSet("save_answers", other.SaveAnswers) {
+		c.SaveAnswers = other.SaveAnswers
+	}
+	if other.isSet("sandbox", other.Sandbox) {
+		c.Sandbox = other.Sandbox
+	}
+	if other.SandboxRoots != nil {
+		c.SandboxRoots = other.SandboxRoots
+	}
+	if other.AllowOutsideOutput != nil {
+		c.AllowOutsideOutput = other.AllowOutsideOutput
+	}
+	if other.SecretKeys != nil {
+		c.SecretKeys = other.SecretKeys
+	}
+	if other.isSet("strict_path_variables", other.StrictPathVariables) {
+		c.StrictPathVariables = other.StrictPathVariables
+	}
+	if other.Each != nil {
+		c.Each = other.Each
+	}
+	if other.isSet("skip_empty", other.SkipEmpty) {
+		c.SkipEmpty = other.SkipEmpty
+	}
+	if other.SkipEmptyTemplates != nil {
+		c.SkipEmptyTemplates = other.SkipEmptyTemplates
+	}
+}
+
+// lookupBool 读取布尔类型的环境变量并将对应的配置项标记为显式设置，未设置时不修改 dst
+// name 去掉前缀并转为小写后即为配置文件中的名称，如 SAVE_ANSWERS 对应 save_answers
+func (c *Config) lookupBool(lookup func(string) (string, bool), name string, dst *bool) error {
+	v, ok := lookup(EnvPrefix + name)
+	if !ok {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return errors.Wrapf(err, "无效的环境变量 %s%s: %s", EnvPrefix, name, v)
+	}
+	*dst = b
+	c.MarkSet(strings.ToLower(name))
+	return nil
+}
+
+// SplitList 拆分逗号分隔的列表，忽略空项
+func SplitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// resolvePath 将相对路径转换为相对于 baseDir 的路径
+func resolvePath(baseDir, path string) string {
+	if path == "" || filepath.IsAbs(path) || baseDir == "" {
+		return path
+	}
+	return filepath.Join(baseDir, path)
 }
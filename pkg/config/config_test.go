@@ -0,0 +1,260 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindConfigFile(t *testing.T) {
+	rootDir, err := os.MkdirTemp("", "config_find_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(rootDir)
+
+	nestedDir := filepath.Join(rootDir, "a", "b")
+	if err := os.MkdirAll(nestedDir, 0755); err != nil {
+		t.Fatalf("Failed to create nested dir: %v", err)
+	}
+
+	// 未找到配置文件时返回空字符串
+	found, err := FindConfigFile(nestedDir)
+	if err != nil {
+		t.Fatalf("FindConfigFile() error = %v", err)
+	}
+	if found != "" && !isOutside(rootDir, found) {
+		t.Errorf("FindConfigFile() = %v, want no config file inside %v", found, rootDir)
+	}
+
+	configPath := filepath.Join(rootDir, ConfigFileName)
+	if err := os.WriteFile(configPath, []byte("config: {}"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	found, err = FindConfigFile(nestedDir)
+	if err != nil {
+		t.Fatalf("FindConfigFile() error = %v", err)
+	}
+	if found != configPath {
+		t.Errorf("FindConfigFile() = %v, want %v", found, configPath)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	rootDir, err := os.MkdirTemp("", "config_load_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(rootDir)
+
+	workDir := filepath.Join(rootDir, "project", "sub")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("Failed to create work dir: %v", err)
+	}
+
+	configContent := `config:
+  template_dir: tpl
+  output_dir: /abs/out
+  variable_files:
+    - vars/a.yaml
+  skip_template_suffixes: .vue.tpl
+  skip_template_prefixes: web
+`
+	configPath := filepath.Join(rootDir, "project", ConfigFileName)
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	t.Setenv(EnvPrefix+"SKIP_PREFIXES", "server")
+
+	cfg, used, err := Load(workDir, "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if used != configPath {
+		t.Errorf("Load() config file = %v, want %v", used, configPath)
+	}
+
+	projectDir := filepath.Join(rootDir, "project")
+	want := &Config{
+		TemplateDir:          filepath.Join(projectDir, "tpl"),
+		VariablesDir:         filepath.Join(workDir, DefaultVariablesDir),
+		OutputDir:            "/abs/out",
+		VariableFiles:        []string{filepath.Join(projectDir, "vars/a.yaml")},
+		SkipTemplateSuffixes: ".vue.tpl",
+		SkipTemplatePrefixes: "server",
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Load() = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		EnvPrefix + "TEMPLATE_DIR":          "templates",
+		EnvPrefix + "VARIABLE_FILES":        "a.yaml, b.yaml,",
+		EnvPrefix + "JOBS":                  "4",
+		EnvPrefix + "ENV_PREFIX":            "GEN_",
+		EnvPrefix + "SAVE_ANSWERS":          "true",
+		EnvPrefix + "PROFILES":              "prod,eu",
+		EnvPrefix + "SANDBOX":               "true",
+		EnvPrefix + "SANDBOX_ROOTS":         "shared,/opt/templates",
+		EnvPrefix + "ALLOW_OUTSIDE_OUTPUT":  "shared/**",
+		EnvPrefix + "STRICT_PATH_VARIABLES": "true",
+		EnvPrefix + "SKIP_EMPTY":            "true",
+		EnvPrefix + "SKIP_EMPTY_TEMPLATES":  "docs/**,*.md.tpl",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	cfg := Default()
+	if err := cfg.ApplyEnv("/work", lookup); err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+
+	if cfg.TemplateDir != filepath.Join("/work", "templates") {
+		t.Errorf("TemplateDir = %v, want %v", cfg.TemplateDir, filepath.Join("/work", "templates"))
+	}
+	if cfg.OutputDir != DefaultOutputDir {
+		t.Errorf("OutputDir = %v, want %v", cfg.OutputDir, DefaultOutputDir)
+	}
+	wantFiles := []string{filepath.Join("/work", "a.yaml"), filepath.Join("/work", "b.yaml")}
+	if !reflect.DeepEqual(cfg.VariableFiles, wantFiles) {
+		t.Errorf("VariableFiles = %v, want %v", cfg.VariableFiles, wantFiles)
+	}
+	if cfg.Jobs != 4 {
+		t.Errorf("Jobs = %v, want %v", cfg.Jobs, 4)
+	}
+	if cfg.VariableEnvPrefix != "GEN_" {
+		t.Errorf("VariableEnvPrefix = %v, want %v", cfg.VariableEnvPrefix, "GEN_")
+	}
+	if !cfg.SaveAnswers {
+		t.Error("SaveAnswers = false, want true")
+	}
+	if !reflect.DeepEqual(cfg.Profiles, []string{"prod", "eu"}) {
+		t.Errorf("Profiles = %v, want [prod eu]", cfg.Profiles)
+	}
+	wantRoots := []string{filepath.Join("/work", "shared"), "/opt/templates"}
+	if !cfg.Sandbox || !reflect.DeepEqual(cfg.SandboxRoots, wantRoots) {
+		t.Errorf("Sandbox = %v, SandboxRoots = %v, want true, %v", cfg.Sandbox, cfg.SandboxRoots, wantRoots)
+	}
+	if !reflect.DeepEqual(cfg.AllowOutsideOutput, []string{"shared/**"}) {
+		t.Errorf("AllowOutsideOutput = %v, want [shared/**]", cfg.AllowOutsideOutput)
+	}
+	if !cfg.StrictPathVariables {
+		t.Error("StrictPathVariables = false, want true")
+	}
+	if !cfg.SkipEmpty || !reflect.DeepEqual(cfg.SkipEmptyTemplates, []string{"docs/**", "*.md.tpl"}) {
+		t.Errorf("SkipEmpty = %v, SkipEmptyTemplates = %v, want true, [docs/** *.md.tpl]", cfg.SkipEmpty, cfg.SkipEmptyTemplates)
+	}
+
+	env[EnvPrefix+"JOBS"] = "many"
+	if err := Default().ApplyEnv("/work", lookup); err == nil {
+		t.Error("ApplyEnv() expected error for invalid GENERATOR_JOBS, got nil")
+	}
+}
+
+func TestMergeExplicitZero(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "config_zero_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	configPath := filepath.Join(tempDir, ConfigFileName)
+	configContent := `config:
+  jobs: 4
+  save_answers: true
+  allow_orphaned_regions: true
+  disable_manifest: true
+  sandbox: true
+  strict_path_variables: true
+  skip_empty: true
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	env := map[string]string{
+		EnvPrefix + "SAVE_ANSWERS":          "false",
+		EnvPrefix + "SANDBOX":               "false",
+		EnvPrefix + "STRICT_PATH_VARIABLES": "false",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	cfg := Default()
+	if err := cfg.ApplyFile(configPath); err != nil {
+		t.Fatalf("ApplyFile() error = %v", err)
+	}
+	if cfg.Jobs != 4 || !cfg.SaveAnswers {
+		t.Fatalf("ApplyFile() Jobs = %v, SaveAnswers = %v, want 4, true", cfg.Jobs, cfg.SaveAnswers)
+	}
+
+	// 较高优先级的来源显式设置的 false 和 0 覆盖配置文件中的值
+	if err := cfg.ApplyEnv(tempDir, lookup); err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+	flagCfg := &Config{}
+	cfg.Merge(flagCfg.MarkSet("jobs", "allow_orphaned_regions", "disable_manifest", "skip_empty"))
+
+	if cfg.Jobs != 0 {
+		t.Errorf("Jobs = %v, want 0", cfg.Jobs)
+	}
+	if cfg.SaveAnswers {
+		t.Error("SaveAnswers = true, want false")
+	}
+	if cfg.AllowOrphanedRegions {
+		t.Error("AllowOrphanedRegions = true, want false")
+	}
+	if cfg.DisableManifest {
+		t.Error("DisableManifest = true, want false")
+	}
+	if cfg.Sandbox {
+		t.Error("Sandbox = true, want false")
+	}
+	if cfg.StrictPathVariables {
+		t.Error("StrictPathVariables = true, want false")
+	}
+	if cfg.SkipEmpty {
+		t.Error("SkipEmpty = true, want false")
+	}
+
+	// 未显式设置的零值不覆盖
+	cfg.Merge(&Config{Jobs: 2})
+	cfg.Merge(&Config{})
+	if cfg.Jobs != 2 {
+		t.Errorf("Jobs = %v, want 2", cfg.Jobs)
+	}
+}
+
+func TestApplyFileInvalid(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "config_invalid_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	configPath := filepath.Join(tempDir, ConfigFileName)
+	if err := os.WriteFile(configPath, []byte("config: ["), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	if err := Default().ApplyFile(configPath); err == nil {
+		t.Error("ApplyFile() expected error for invalid yaml, got nil")
+	}
+	if err := Default().ApplyFile(filepath.Join(tempDir, "missing.yaml")); err == nil {
+		t.Error("ApplyFile() expected error for missing file, got nil")
+	}
+}
+
+// isOutside 判断 path 是否位于 dir 之外
+func isOutside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err != nil || rel == ".." || len(rel) > 2 && rel[:3] == ".."+string(filepath.Separator)
+}
@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clh021/generator/pkg/config"
+	"github.com/pkg/errors"
+)
+
+func TestGenerateFiles_Concurrent(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "generator_concurrent_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// 大量模板共享同一个子模板，验证并发 include 与结果顺序
+	files := map[string]string{
+		"templates/shared__child__.tpl": "shared {{ .name }}",
+		"variables/variables.yaml":      "name: test",
+	}
+	const count = 200
+	for i := 0; i < count; i++ {
+		files[fmt.Sprintf("templates/f%03d.txt.tpl", i)] = fmt.Sprintf("%d {{ include \"shared__child__.tpl\" . }}", i)
+	}
+	writeTestFiles(t, tempDir, files)
+
+	for _, jobs := range []int{1, 8, 0} {
+		t.Run(fmt.Sprintf("jobs=%d", jobs), func(t *testing.T) {
+			cfg := &config.Config{
+				TemplateDir:  filepath.Join(tempDir, "templates"),
+				VariablesDir: filepath.Join(tempDir, "variables"),
+				OutputDir:    filepath.Join(tempDir, "output"),
+				Jobs:         jobs,
+			}
+			generated, err := NewGenerator().GenerateFiles(cfg)
+			if err != nil {
+				t.Fatalf("GenerateFiles() error = %v", err)
+			}
+			if len(generated) != count {
+				t.Fatalf("GenerateFiles() returned %d files, want %d", len(generated), count)
+			}
+			for i, file := range generated {
+				wantPath := filepath.Join(cfg.OutputDir, fmt.Sprintf("f%03d.txt", i))
+				wantContent := fmt.Sprintf("%d shared test", i)
+				if file.OutputPath != wantPath || file.Content != wantContent {
+					t.Fatalf("file %d = %s %q, want %s %q", i, file.OutputPath, file.Content, wantPath, wantContent)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateFiles_AggregatesErrors(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "generator_errors_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/a.txt.tpl":      "{{ .missing }}",
+		"templates/b.txt.tpl":      "ok",
+		"templates/c.txt.tpl":      "{{ .name ",
+		"variables/variables.yaml": "name: test",
+	})
+
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+		Jobs:         4,
+	}
+	_, err = NewGenerator().GenerateFiles(cfg)
+	if err == nil {
+		t.Fatal("GenerateFiles() expected error, got nil")
+	}
+
+	multi, ok := errors.Cause(err).(*MultiError)
+	if !ok {
+		t.Fatalf("GenerateFiles() error type = %T, want *MultiError", errors.Cause(err))
+	}
+	if len(multi.Errors) != 2 {
+		t.Fatalf("MultiError has %d errors, want 2: %v", len(multi.Errors), multi)
+	}
+	// 错误按模板扫描顺序排列
+	if !strings.Contains(multi.Errors[0].Error(), "a.txt.tpl") || !strings.Contains(multi.Errors[1].Error(), "c.txt.tpl") {
+		t.Errorf("MultiError order = %v", multi)
+	}
+	if !strings.HasPrefix(multi.Error(), "2 个模板生成失败") {
+		t.Errorf("MultiError.Error() = %q", multi.Error())
+	}
+}
@@ -0,0 +1,269 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines 统一差异格式中每个变更块前后保留的上下文行数
+const diffContextLines = 3
+
+// diffOpKind 表示行差异操作的类型
+type diffOpKind int
+
+const (
+	diffEqual diffOpKind = iota
+	diffDelete
+	diffInsert
+)
+
+// diffOp 表示一行的差异操作
+// aIndex/bIndex 分别为该行在旧内容/新内容中的下标，不适用时为 -1
+type diffOp struct {
+	kind   diffOpKind
+	aIndex int
+	bIndex int
+}
+
+// splitLines 按行拆分内容，每行保留结尾的换行符
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines 使用 Myers 算法计算从 a 到 b 的最短行编辑序列
+// 采用线性空间的分治版本：从两端同时搜索找到编辑路径的中间点，再分别计算两侧，
+// 内存占用与行数成正比，而不是与编辑距离的平方成正比
+func diffLines(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	return diffRange(a, b, 0, len(a), 0, len(b), ops)
+}
+
+// diffRange 计算 a[aLo:aHi] 到 b[bLo:bHi] 的编辑序列并追加到 ops
+func diffRange(a, b []string, aLo, aHi, bLo, bHi int, ops []diffOp) []diffOp {
+	// 相同的前缀和后缀不参与搜索
+	for aLo < aHi && bLo < bHi && a[aLo] == b[bLo] {
+		ops = append(ops, diffOp{kind: diffEqual, aIndex: aLo, bIndex: bLo})
+		aLo++
+		bLo++
+	}
+	aEnd, bEnd := aHi, bHi
+	for aLo < aEnd && bLo < bEnd && a[aEnd-1] == b[bEnd-1] {
+		aEnd--
+		bEnd--
+	}
+
+	if aLo < aEnd && bLo < bEnd {
+		if x, y, ok := middlePoint(a, b, aLo, aEnd, bLo, bEnd); ok {
+			ops = diffRange(a, b, aLo, x, bLo, y, ops)
+			ops = diffRange(a, b, x, aEnd, y, bEnd, ops)
+			aLo, bLo = aEnd, bEnd
+		}
+	}
+	for ; aLo < aEnd; aLo++ {
+		ops = append(ops, diffOp{kind: diffDelete, aIndex: aLo, bIndex: -1})
+	}
+	for ; bLo < bEnd; bLo++ {
+		ops = append(ops, diffOp{kind: diffInsert, aIndex: -1, bIndex: bLo})
+	}
+
+	for i := 0; aEnd+i < aHi; i++ {
+		ops = append(ops, diffOp{kind: diffEqual, aIndex: aEnd + i, bIndex: bEnd + i})
+	}
+	return ops
+}
+
+// middlePoint 从 (aLo, bLo) 向后和从 (aHi, bHi) 向前同时搜索最短编辑路径，
+// 返回两个方向的路径相遇处的坐标，以此将问题分为两个更小的部分；无法分割时 ok 为 false
+func middlePoint(a, b []string, aLo, aHi, bLo, bHi int) (x, y int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// vf[k]/vb[k] 为正向/反向搜索在对角线 k 上到达的最远 x（反向时从末尾算起），-1 表示尚未到达
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	delta := n - m
+	// 总编辑距离为奇数时在正向搜索中检查相遇，否则在反向搜索中检查
+	front := delta%2 != 0
+	// 超出边界的对角线不再搜索
+	kfStart, kfEnd, kbStart, kbEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + kfStart; k <= d-kfEnd; k += 2 {
+			i := offset + k
+			var fx int
+			if k == -d || (k != d && vf[i-1] < vf[i+1]) {
+				fx = vf[i+1]
+			} else {
+				fx = vf[i-1] + 1
+			}
+			fy := fx - k
+			for fx < n && fy < m && a[aLo+fx] == b[bLo+fy] {
+				fx++
+				fy++
+			}
+			vf[i] = fx
+			switch {
+			case fx > n:
+				kfEnd += 2
+			case fy > m:
+				kfStart += 2
+			case front:
+				j := offset + delta - k
+				if j >= 0 && j < len(vb) && vb[j] != -1 && fx >= n-vb[j] {
+					return split(aLo, aHi, bLo, bHi, fx, fy)
+				}
+			}
+		}
+
+		for k := -d + kbStart; k <= d-kbEnd; k += 2 {
+			i := offset + k
+			var bx int
+			if k == -d || (k != d && vb[i-1] < vb[i+1]) {
+				bx = vb[i+1]
+			} else {
+				bx = vb[i-1] + 1
+			}
+			by := bx - k
+			for bx < n && by < m && a[aHi-bx-1] == b[bHi-by-1] {
+				bx++
+				by++
+			}
+			vb[i] = bx
+			switch {
+			case bx > n:
+				kbEnd += 2
+			case by > m:
+				kbStart += 2
+			case !front:
+				j := offset + delta - k
+				if j >= 0 && j < len(vf) && vf[j] != -1 {
+					fx := vf[j]
+					fy := offset + fx - j
+					if fx >= n-bx {
+						return split(aLo, aHi, bLo, bHi, fx, fy)
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// split 将相对坐标转换为绝对坐标，分割点位于端点时无法缩小问题，返回 false
+func split(aLo, aHi, bLo, bHi, x, y int) (int, int, bool) {
+	x, y = aLo+x, bLo+y
+	if (x == aLo && y == bLo) || (x == aHi && y == bHi) {
+		return 0, 0, false
+	}
+	return x, y, true
+}
+
+// UnifiedDiff 生成 oldContent 到 newContent 的统一差异格式文本
+// 内容相同时返回空字符串
+func UnifiedDiff(oldName, newName, oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+
+	a := splitLines(oldContent)
+	b := splitLines(newContent)
+	ops := diffLines(a, b)
+
+	// aPos[i]/bPos[i] 为 ops[i] 之前旧/新内容已经过的行数
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if op.aIndex >= 0 {
+			aPos[i+1]++
+		}
+		if op.bIndex >= 0 {
+			bPos[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n", oldName)
+	fmt.Fprintf(&sb, "+++ %s\n", newName)
+
+	for start := 0; start < len(ops); {
+		// 找到下一处变更
+		for start < len(ops) && ops[start].kind == diffEqual {
+			start++
+		}
+		if start >= len(ops) {
+			break
+		}
+
+		// 向后扩展变更块，两处变更间的相同行不超过 2*上下文 时合并为一个块
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != diffEqual {
+				end = i + 1
+				continue
+			}
+			if i-end >= 2*diffContextLines {
+				break
+			}
+		}
+
+		hunkStart := start - diffContextLines
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := end + diffContextLines
+		if hunkEnd > len(ops) {
+			hunkEnd = len(ops)
+		}
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(aPos[hunkStart], aPos[hunkEnd]-aPos[hunkStart]),
+			hunkRange(bPos[hunkStart], bPos[hunkEnd]-bPos[hunkStart]))
+		for _, op := range ops[hunkStart:hunkEnd] {
+			switch op.kind {
+			case diffEqual:
+				writeDiffLine(&sb, " ", a[op.aIndex])
+			case diffDelete:
+				writeDiffLine(&sb, "-", a[op.aIndex])
+			case diffInsert:
+				writeDiffLine(&sb, "+", b[op.bIndex])
+			}
+		}
+		start = hunkEnd
+	}
+
+	return sb.String()
+}
+
+// hunkRange 格式化变更块的行范围，before 为块之前的行数
+// 与 GNU diff 一致，空范围的起始行号为变更位置之前的行
+func hunkRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, count)
+	}
+}
+
+// writeDiffLine 输出一行差异，没有结尾换行符的行追加提示
+func writeDiffLine(sb *strings.Builder, prefix, line string) {
+	sb.WriteString(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
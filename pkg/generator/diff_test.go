@@ -0,0 +1,151 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{
+			name: "identical",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "new file",
+			old:  "",
+			new:  "a\nb\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "change in middle",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			new:  "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			name: "missing trailing newline",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "delete all",
+			old:  "a\n",
+			new:  "",
+			want: "--- old\n+++ new\n@@ -1 +0,0 @@\n-a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff("old", "new", tt.old, tt.new)
+			if got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLines_Roundtrip(t *testing.T) {
+	a := splitLines("a\nb\nc\nd\ne\nf\n")
+	b := splitLines("x\nb\nc\ny\ne\nf\nz\n")
+
+	var rebuiltA, rebuiltB []string
+	for _, op := range diffLines(a, b) {
+		switch op.kind {
+		case diffEqual:
+			rebuiltA = append(rebuiltA, a[op.aIndex])
+			rebuiltB = append(rebuiltB, b[op.bIndex])
+		case diffDelete:
+			rebuiltA = append(rebuiltA, a[op.aIndex])
+		case diffInsert:
+			rebuiltB = append(rebuiltB, b[op.bIndex])
+		}
+	}
+
+	if strings.Join(rebuiltA, "") != strings.Join(a, "") {
+		t.Errorf("rebuilt old content = %q, want %q", strings.Join(rebuiltA, ""), strings.Join(a, ""))
+	}
+	if strings.Join(rebuiltB, "") != strings.Join(b, "") {
+		t.Errorf("rebuilt new content = %q, want %q", strings.Join(rebuiltB, ""), strings.Join(b, ""))
+	}
+}
+
+func TestDiffLines_Large(t *testing.T) {
+	const n = 5000
+	old := make([]string, n)
+	rewritten := make([]string, n)
+	interleaved := make([]string, n)
+	for i := 0; i < n; i++ {
+		old[i] = fmt.Sprintf("old %d\n", i)
+		rewritten[i] = fmt.Sprintf("new %d\n", i)
+		// 每隔一行修改一次，编辑点分散在整个文件中
+		interleaved[i] = old[i]
+		if i%2 == 1 {
+			interleaved[i] = rewritten[i]
+		}
+	}
+
+	tests := []struct {
+		name    string
+		a, b    []string
+		deletes int
+		inserts int
+	}{
+		{"全部重写", old, rewritten, n, n},
+		{"隔行修改", old, interleaved, n / 2, n / 2},
+		{"删除后半部分", old, old[:n/2], n / 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rebuiltA, rebuiltB []string
+			deletes, inserts := 0, 0
+			for _, op := range diffLines(tt.a, tt.b) {
+				switch op.kind {
+				case diffEqual:
+					rebuiltA = append(rebuiltA, tt.a[op.aIndex])
+					rebuiltB = append(rebuiltB, tt.b[op.bIndex])
+				case diffDelete:
+					rebuiltA = append(rebuiltA, tt.a[op.aIndex])
+					deletes++
+				case diffInsert:
+					rebuiltB = append(rebuiltB, tt.b[op.bIndex])
+					inserts++
+				}
+			}
+
+			if strings.Join(rebuiltA, "") != strings.Join(tt.a, "") {
+				t.Error("rebuilt old content does not match")
+			}
+			if strings.Join(rebuiltB, "") != strings.Join(tt.b, "") {
+				t.Error("rebuilt new content does not match")
+			}
+			// 编辑序列应当是最短的
+			if deletes != tt.deletes || inserts != tt.inserts {
+				t.Errorf("deletes = %d, inserts = %d, want %d, %d", deletes, inserts, tt.deletes, tt.inserts)
+			}
+		})
+	}
+
+	// 全部重写时只有一个覆盖整个文件的 hunk
+	got := UnifiedDiff("old", "new", strings.Join(old, ""), strings.Join(rewritten, ""))
+	if !strings.Contains(got, fmt.Sprintf("@@ -1,%d +1,%d @@", n, n)) || strings.Count(got, "@@ -") != 1 {
+		t.Errorf("UnifiedDiff() should produce a single hunk covering the whole file")
+	}
+}
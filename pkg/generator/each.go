@@ -0,0 +1,124 @@
+package generator
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/clh021/generator/pkg/config"
+	"github.com/clh021/generator/pkg/variables"
+	"github.com/pkg/errors"
+)
+
+// DefaultEachName 未指定名称时列表项在模板和输出路径中的变量名
+const DefaultEachName = "item"
+
+// eachNamePattern 列表项名称必须能同时用作模板字段（.route）和路径变量（__route__），
+// 因此只能包含字母、数字和单个下划线，且不能以下划线开头或结尾
+var eachNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(_[A-Za-z0-9]+)*$`)
+
+// EachSource 按列表逐项生成时的列表来源
+type EachSource struct {
+	// 列表变量的路径，如 routes、api.entities
+	List string
+	// 列表项的变量名，列表项的下标为 <Name>_index
+	Name string
+}
+
+// IndexName 返回列表项下标的变量名
+func (s EachSource) IndexName() string {
+	return s.Name + "_index"
+}
+
+// ParseEach 解析列表来源，格式为 "<变量路径> [as <名称>]"，如 "routes as route"
+// 未指定名称时使用 DefaultEachName
+func ParseEach(s string) (EachSource, error) {
+	fields := strings.Fields(s)
+	source := EachSource{Name: DefaultEachName}
+	switch {
+	case len(fields) == 1:
+		source.List = fields[0]
+	case len(fields) == 3 && fields[1] == "as":
+		source.List, source.Name = fields[0], fields[2]
+	default:
+		return EachSource{}, errors.Errorf("无效的列表来源 %q，格式应为 <变量路径> [as <名称>]", s)
+	}
+	if !eachNamePattern.MatchString(source.Name) {
+		return EachSource{}, errors.Errorf("无效的列表项名称 %q，只能包含字母、数字和单个下划线，且以字母开头", source.Name)
+	}
+	return source, nil
+}
+
+// Items 返回 vars 中的列表，变量不存在或不是列表时返回错误
+func (s EachSource) Items(vars map[string]interface{}) ([]interface{}, error) {
+	value, ok := variables.Get(vars, s.List)
+	if !ok {
+		return nil, errors.Errorf("列表变量 %s 不存在", s.List)
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("变量 %s 不是列表（实际为 %T）", s.List, value)
+	}
+	return items, nil
+}
+
+// matchEach 返回第一个匹配模板相对路径的规则的列表来源，规则应已通过 validateEachRules 校验
+func matchEach(rules []config.EachRule, relativePath string) (EachSource, bool) {
+	slashPath := filepath.ToSlash(relativePath)
+	for _, rule := range rules {
+		if ok, _ := doublestar.Match(rule.Pattern, slashPath); ok {
+			source, err := ParseEach(rule.Each)
+			return source, err == nil
+		}
+	}
+	return EachSource{}, false
+}
+
+// validateEachRules 校验按列表逐项生成的规则
+func validateEachRules(rules []config.EachRule) error {
+	for _, rule := range rules {
+		if !doublestar.ValidatePattern(rule.Pattern) {
+			return errors.Errorf("无效的匹配模式: %s", rule.Pattern)
+		}
+		if _, err := ParseEach(rule.Each); err != nil {
+			return errors.Wrapf(err, "each 规则 %s", rule.Pattern)
+		}
+	}
+	return nil
+}
+
+// checkOutputCollisions 检查按列表逐项生成的文件是否与其他生成的文件使用相同的输出路径
+// 普通模板之间的重复保持原有行为，后生成的覆盖先生成的
+func checkOutputCollisions(files []GeneratedFile, templateDir string) error {
+	seen := make(map[string]int, len(files))
+	var errs []error
+	for i, file := range files {
+		j, ok := seen[file.OutputPath]
+		if !ok {
+			seen[file.OutputPath] = i
+			continue
+		}
+		if file.Item == "" && files[j].Item == "" {
+			continue
+		}
+		errs = append(errs, errors.Errorf("输出路径冲突: %s 同时由 %s 和 %s 生成，请在输出路径中使用列表项的变量区分各个文件",
+			file.OutputPath, describeSource(files[j], templateDir), describeSource(file, templateDir)))
+	}
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
+
+// describeSource 返回生成文件的模板及列表项，如 handlers/__route.name__.go.tpl（routes[1]）
+func describeSource(file GeneratedFile, templateDir string) string {
+	source := file.TemplatePath
+	if rel, err := filepath.Rel(templateDir, file.TemplatePath); err == nil {
+		source = filepath.ToSlash(rel)
+	}
+	if file.Item != "" {
+		source += "（" + file.Item + "）"
+	}
+	return source
+}
@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clh021/generator/pkg/config"
+)
+
+func TestParseEach(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    EachSource
+		wantErr bool
+	}{
+		{input: "routes", want: EachSource{List: "routes", Name: "item"}},
+		{input: "routes as route", want: EachSource{List: "routes", Name: "route"}},
+		{input: " api.entities  as  api_entity ", want: EachSource{List: "api.entities", Name: "api_entity"}},
+		{input: "", wantErr: true},
+		{input: "routes route", wantErr: true},
+		{input: "routes as", wantErr: true},
+		{input: "routes as 1route", wantErr: true},
+		// 名称需要能用作路径变量 __route__
+		{input: "routes as _route", wantErr: true},
+		{input: "routes as my__route", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseEach(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEach() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseEach() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateFiles_Each(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "each_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/handlers/__route.name|snake__.go.tpl": "{{ .route_index }} {{ .route.method }} {{ pascal .route.name }} {{ .project }}",
+		"templates/entities/__item__.txt.tpl":            "{{ .item }}",
+		"templates/README.md.tpl":                        "{{ len .routes }}",
+		"variables/variables.yaml": `project: demo
+routes:
+  - {name: UserProfile, method: GET}
+  - {name: orders, method: POST}
+model: {entities: [user, order]}
+`,
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+		Each: []config.EachRule{
+			{Pattern: "handlers/**", Each: "routes as route"},
+			{Pattern: "entities/*", Each: "model.entities"},
+		},
+	}
+
+	generated, err := NewGenerator().GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	got := make(map[string]GeneratedFile)
+	for _, file := range generated {
+		rel, _ := filepath.Rel(cfg.OutputDir, file.OutputPath)
+		got[filepath.ToSlash(rel)] = file
+	}
+	want := map[string]struct{ content, item string }{
+		"handlers/user_profile.go": {"0 GET UserProfile demo", "routes[0]"},
+		"handlers/orders.go":       {"1 POST Orders demo", "routes[1]"},
+		"entities/user.txt":        {"user", "model.entities[0]"},
+		"entities/order.txt":       {"order", "model.entities[1]"},
+		"README.md":                {"2", ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GenerateFiles() generated %d files, want %d: %+v", len(got), len(want), generated)
+	}
+	for rel, w := range want {
+		file, ok := got[rel]
+		if !ok {
+			t.Errorf("GenerateFiles() did not generate %s", rel)
+			continue
+		}
+		if file.Content != w.content || file.Item != w.item {
+			t.Errorf("%s: content = %q, item = %q, want %q, %q", rel, file.Content, file.Item, w.content, w.item)
+		}
+	}
+
+	// 列表项的输出路径相同时报错
+	cfg.Include = []string{"README.md.tpl"}
+	cfg.Each = []config.EachRule{{Pattern: "README.md.tpl", Each: "routes"}}
+	_, err = NewGenerator().GenerateFiles(cfg)
+	if err == nil || !strings.Contains(err.Error(), "输出路径冲突") || !strings.Contains(err.Error(), "README.md.tpl（routes[1]）") {
+		t.Errorf("GenerateFiles() error = %v, want output path collision", err)
+	}
+
+	// 列表变量不存在或不是列表
+	for _, each := range []string{"missing", "project"} {
+		cfg.Each = []config.EachRule{{Pattern: "README.md.tpl", Each: each}}
+		if _, err := NewGenerator().GenerateFiles(cfg); err == nil {
+			t.Errorf("GenerateFiles() with each %q expected error, got nil", each)
+		}
+	}
+
+	cfg.Each = []config.EachRule{{Pattern: "README.md.tpl", Each: "routes as"}}
+	if _, err := NewGenerator().GenerateFiles(cfg); err == nil {
+		t.Error("GenerateFiles() expected error for invalid each rule, got nil")
+	}
+}
@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/clh021/generator/pkg/variables"
+	"github.com/pkg/errors"
+)
+
+// EnvPathSeparator 环境变量名中表示嵌套层级的分隔符，GEN_PROJECT__NAME 对应 project.name
+const EnvPathSeparator = "__"
+
+// EnvVariableSource 从带前缀的环境变量中读取模板变量
+//
+// 去掉前缀后的变量名按 __ 拆分为变量路径并转换为小写，单个 _ 保留：
+//
+//	GEN_PROJECT__NAME=demo  ->  project.name: demo
+//	GEN_BUILD_ID=42         ->  build_id: 42
+//
+// 值按以下规则转换类型：true/false 为布尔值，整数为 int，以 { 或 [ 开头的合法 JSON 按 JSON 解析，其余为字符串
+type EnvVariableSource struct {
+	// 环境变量前缀，如 GEN_
+	Prefix string
+	// 返回 KEY=value 形式的环境变量列表，默认为 os.Environ
+	Environ func() []string
+}
+
+// NewEnvVariableSource 创建读取进程环境变量的变量源
+func NewEnvVariableSource(prefix string) *EnvVariableSource {
+	return &EnvVariableSource{
+		Prefix:  prefix,
+		Environ: os.Environ,
+	}
+}
+
+// LoadVariables 返回前缀匹配的环境变量组成的变量树
+func (s *EnvVariableSource) LoadVariables() (map[string]interface{}, error) {
+	vars, _, err := s.LoadVariablesWithProvenance()
+	return vars, err
+}
+
+// LoadVariablesWithProvenance 与 LoadVariables 相同，同时返回每个值来自哪个环境变量
+func (s *EnvVariableSource) LoadVariablesWithProvenance() (map[string]interface{}, *variables.Provenance, error) {
+	vars := make(map[string]interface{})
+	provenance := variables.NewProvenance()
+	if s.Prefix == "" {
+		return vars, provenance, nil
+	}
+
+	for _, env := range s.Environ() {
+		key, value, ok := strings.Cut(env, "=")
+		if !ok || !strings.HasPrefix(key, s.Prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(key, s.Prefix)
+		if name == "" {
+			continue
+		}
+
+		path := strings.Split(strings.ToLower(name), EnvPathSeparator)
+		for _, segment := range path {
+			if segment == "" {
+				return nil, nil, errors.Errorf("无效的环境变量名 %s，变量路径中存在空的层级", key)
+			}
+		}
+		v := coerceEnvValue(value)
+		if err := setEnvVariable(vars, path, v); err != nil {
+			return nil, nil, errors.Wrapf(err, "环境变量 %s", key)
+		}
+		if err := provenance.Record(variables.JoinPath(path...), v, variables.Origin{File: "环境变量 " + key}); err != nil {
+			return nil, nil, errors.Wrapf(err, "环境变量 %s", key)
+		}
+	}
+	return vars, provenance, nil
+}
+
+// setEnvVariable 按路径设置变量，中间层级不存在时创建映射
+func setEnvVariable(vars map[string]interface{}, path []string, value interface{}) error {
+	for i, segment := range path[:len(path)-1] {
+		child, ok := vars[segment]
+		if !ok {
+			m := make(map[string]interface{})
+			vars[segment] = m
+			vars = m
+			continue
+		}
+		m, ok := child.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("%s 已设置为非映射的值", strings.Join(path[:i+1], "."))
+		}
+		vars = m
+	}
+
+	last := path[len(path)-1]
+	if existing, ok := vars[last].(map[string]interface{}); ok {
+		// GEN_DB='{"port":1}' 与 GEN_DB__HOST=x 同时设置时合并
+		if m, ok := value.(map[string]interface{}); ok {
+			vars[last] = variables.Merge(existing, m, variables.MergeOptions{})
+			return nil
+		}
+		return errors.Errorf("%s 已设置为映射", strings.Join(path, "."))
+	}
+	vars[last] = value
+	return nil
+}
+
+// coerceEnvValue 将环境变量的值转换为布尔值、整数或 JSON 值
+func coerceEnvValue(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+	if strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[") {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err == nil {
+			return variables.NormalizeJSON(v)
+		}
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/clh021/generator/pkg/config"
+	"github.com/clh021/generator/pkg/variables"
+)
+
+func TestEnvVariableSource_LoadVariables(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		environ []string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "nested paths and type coercion",
+			prefix: "GEN_",
+			environ: []string{
+				"GEN_PROJECT__NAME=demo",
+				"GEN_PROJECT__DEBUG=true",
+				"GEN_BUILD_ID=42",
+				"GEN_VERSION=1.10",
+				`GEN_TAGS=["a","b"]`,
+				`GEN_DB={"port":5432}`,
+				"GEN_DB__HOST=localhost",
+				"GEN_BROKEN_JSON={not json",
+				"OTHER_NAME=ignored",
+				"GEN_=ignored",
+			},
+			want: map[string]interface{}{
+				"project":     map[string]interface{}{"name": "demo", "debug": true},
+				"build_id":    42,
+				"version":     "1.10",
+				"tags":        []interface{}{"a", "b"},
+				"db":          map[string]interface{}{"port": 5432, "host": "localhost"},
+				"broken_json": "{not json",
+			},
+		},
+		{
+			name:    "empty prefix disables the source",
+			environ: []string{"PATH=/bin"},
+			want:    map[string]interface{}{},
+		},
+		{
+			name:    "empty path segment",
+			prefix:  "GEN_",
+			environ: []string{"GEN_A____B=x"},
+			wantErr: true,
+		},
+		{
+			name:    "scalar and nested value conflict",
+			prefix:  "GEN_",
+			environ: []string{"GEN_DB=x", "GEN_DB__HOST=y"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &EnvVariableSource{Prefix: tt.prefix, Environ: func() []string { return tt.environ }}
+			got, err := source.LoadVariables()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadVariables() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateFiles_EnvVariablePrecedence(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "env_variables_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/__name__.txt.tpl": "{{ .project.name }} {{ .project.version }} {{ .project.owner }}",
+		"variables/variables.yaml":   "name: file\nproject:\n  name: file\n  version: 1\n  owner: file\n",
+	})
+	t.Setenv("GENTEST_NAME", "env")
+	t.Setenv("GENTEST_PROJECT__NAME", "env")
+	t.Setenv("GENTEST_PROJECT__VERSION", "2")
+
+	// 变量文件 < 环境变量 < 命令行变量覆盖
+	overrides, err := variables.ParseSet("project.version=3")
+	if err != nil {
+		t.Fatalf("ParseSet() error = %v", err)
+	}
+	cfg := &config.Config{
+		TemplateDir:       filepath.Join(tempDir, "templates"),
+		VariablesDir:      filepath.Join(tempDir, "variables"),
+		OutputDir:         filepath.Join(tempDir, "output"),
+		VariableEnvPrefix: "GENTEST_",
+	}
+	files, err := NewGenerator().WithVariableOverrides(overrides).GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("GenerateFiles() returned %d files, want 1", len(files))
+	}
+
+	wantPath := filepath.Join(cfg.OutputDir, "env.txt")
+	wantContent := "env 3 file"
+	if files[0].OutputPath != wantPath || files[0].Content != wantContent {
+		t.Errorf("file = %s %q, want %s %q", files[0].OutputPath, files[0].Content, wantPath, wantContent)
+	}
+}
@@ -0,0 +1,30 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MultiError 汇总多个模板生成时的错误，按模板扫描顺序排列
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d 个模板生成失败:", len(e.Errors))
+	for _, err := range e.Errors {
+		sb.WriteString("\n  - ")
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap 返回所有错误，支持 errors.Is 和 errors.As
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
@@ -1,5 +1,12 @@
 package generator
 
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 // GeneratedFile 表示一个生成的文件
 type GeneratedFile struct {
 	// 模板文件路径
@@ -8,4 +15,45 @@ type GeneratedFile struct {
 	OutputPath string
 	// 生成的内容
 	Content string
+	// 写入策略，为空时使用写入器的默认策略
+	WritePolicy WritePolicy
+	// 按列表逐项生成时对应的列表项，如 routes[1]，普通模板为空
+	Item string
+	// 为 true 时内容只包含空白的文件不写入，见 Dropped
+	SkipEmpty bool
+	// 上次生成的内容，merge 策略下作为三方合并的共同祖先，nil 表示没有记录
+	Base *string
+}
+
+// FileStatus 表示生成的文件相对于磁盘上现有文件的状态
+type FileStatus string
+
+const (
+	// FileStatusNew 目标文件不存在
+	FileStatusNew FileStatus = "new"
+	// FileStatusChanged 目标文件存在且内容不同
+	FileStatusChanged FileStatus = "changed"
+	// FileStatusUnchanged 目标文件存在且内容相同
+	FileStatusUnchanged FileStatus = "unchanged"
+)
+
+// Dropped 判断文件是否因内容只包含空白而不写入
+func (f GeneratedFile) Dropped() bool {
+	return f.SkipEmpty && strings.TrimSpace(f.Content) == ""
+}
+
+// Status 比较生成的内容与磁盘上的目标文件，返回状态以及现有文件内容
+func (f GeneratedFile) Status() (FileStatus, string, error) {
+	existing, err := os.ReadFile(f.OutputPath)
+	if os.IsNotExist(err) {
+		return FileStatusNew, "", nil
+	}
+	if err != nil {
+		return "", "", errors.Wrapf(err, "读取现有文件失败: %s", f.OutputPath)
+	}
+
+	if string(existing) == f.Content {
+		return FileStatusUnchanged, string(existing), nil
+	}
+	return FileStatusChanged, string(existing), nil
 }
@@ -0,0 +1,46 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneratedFile_Status(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "generated_file_status_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	existingPath := filepath.Join(tempDir, "existing.txt")
+	if err := os.WriteFile(existingPath, []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		file         GeneratedFile
+		wantStatus   FileStatus
+		wantExisting string
+	}{
+		{"new", GeneratedFile{OutputPath: filepath.Join(tempDir, "missing.txt"), Content: "new"}, FileStatusNew, ""},
+		{"changed", GeneratedFile{OutputPath: existingPath, Content: "new"}, FileStatusChanged, "old"},
+		{"unchanged", GeneratedFile{OutputPath: existingPath, Content: "old"}, FileStatusUnchanged, "old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, existing, err := tt.file.Status()
+			if err != nil {
+				t.Fatalf("Status() error = %v", err)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("Status() status = %v, want %v", status, tt.wantStatus)
+			}
+			if existing != tt.wantExisting {
+				t.Errorf("Status() existing = %q, want %q", existing, tt.wantExisting)
+			}
+		})
+	}
+}
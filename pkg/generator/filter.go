@@ -0,0 +1,98 @@
+package generator
+
+import (
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/pkg/errors"
+)
+
+// ControlFileFilter 排除模板目录中控制生成过程的文件：.genignore、变量校验规则文件、问题文件和 .gen_when
+// GenerateFiles 在扫描模板后总是应用它，因此自定义的模板过滤器和扫描器也不会把这些文件当作模板
+type ControlFileFilter struct{}
+
+// ShouldInclude 检查文件是否为模板而不是控制文件
+func (ControlFileFilter) ShouldInclude(path, relativePath string) (bool, string) {
+	slashPath := filepath.ToSlash(relativePath)
+	switch {
+	case slashPath == IgnoreFileName:
+		return false, "忽略规则文件"
+	case isSchemaFile(slashPath):
+		return false, "变量校验规则文件"
+	case slashPath == QuestionsFileName:
+		return false, "问题文件"
+	case filepath.Base(slashPath) == WhenFileName:
+		return false, "条件文件"
+	}
+	return true, ""
+}
+
+// PatternTemplateFilter 基于 glob 模式（支持 ** ）和 .genignore 规则的模板过滤器
+// 模式匹配模板的相对路径（使用 / 分隔）
+type PatternTemplateFilter struct {
+	Base    TemplateFilter // 先执行的过滤器，例如 DefaultTemplateFilter，可为 nil
+	Include []string       // 包含模式，非空时模板必须至少匹配其中一个
+	Exclude []string       // 排除模式，匹配任意一个即排除
+	Ignore  *IgnoreRules   // .genignore 规则，可为 nil
+}
+
+// NewPatternTemplateFilter 创建模式过滤器，并校验所有模式
+func NewPatternTemplateFilter(base TemplateFilter, include, exclude []string, ignore *IgnoreRules) (*PatternTemplateFilter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, errors.Errorf("无效的匹配模式: %s", pattern)
+		}
+	}
+	return &PatternTemplateFilter{
+		Base:    base,
+		Include: include,
+		Exclude: exclude,
+		Ignore:  ignore,
+	}, nil
+}
+
+// NewTemplateFilterForDir 创建组合了默认过滤规则、包含/排除模式以及模板目录中 .genignore 的过滤器
+func NewTemplateFilterForDir(templateDir, skipSuffixes, skipPrefixes string, include, exclude []string) (*PatternTemplateFilter, error) {
+	ignore, err := LoadIgnoreFile(filepath.Join(templateDir, IgnoreFileName))
+	if err != nil {
+		return nil, err
+	}
+	base := NewDefaultTemplateFilter(true, skipSuffixes, skipPrefixes, templateDir)
+	return NewPatternTemplateFilter(base, include, exclude, ignore)
+}
+
+// ShouldInclude 检查是否应该包含指定的模板文件
+func (f *PatternTemplateFilter) ShouldInclude(path, relativePath string) (bool, string) {
+	if f.Base != nil {
+		if include, reason := f.Base.ShouldInclude(path, relativePath); !include {
+			return false, reason
+		}
+	}
+
+	slashPath := filepath.ToSlash(relativePath)
+
+	if len(f.Include) > 0 {
+		matched := false
+		for _, pattern := range f.Include {
+			if ok, _ := doublestar.Match(pattern, slashPath); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, "不匹配任何包含模式"
+		}
+	}
+
+	for _, pattern := range f.Exclude {
+		if ok, _ := doublestar.Match(pattern, slashPath); ok {
+			return false, "排除模式匹配: " + pattern
+		}
+	}
+
+	if ignored, rule := f.Ignore.Match(slashPath); ignored {
+		return false, IgnoreFileName + " 匹配: " + rule
+	}
+
+	return true, ""
+}
@@ -0,0 +1,233 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/clh021/generator/pkg/config"
+)
+
+func TestParseIgnoreRules_Match(t *testing.T) {
+	rules, err := ParseIgnoreRules([]string{
+		"# comment",
+		"",
+		"*.bak",
+		"/root.txt.tpl",
+		"build/",
+		"docs/**/draft*",
+		"secret*",
+		"!secret_keep.tpl",
+		"vendor/",
+		"!vendor/keep.tpl",
+		`\#hash.tpl`,
+	})
+	if err != nil {
+		t.Fatalf("ParseIgnoreRules() error = %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		wantIgnored bool
+		wantRule    string
+	}{
+		{"a.txt.tpl", false, ""},
+		{"x/y/file.bak", true, "*.bak"},
+		{"root.txt.tpl", true, "/root.txt.tpl"},
+		{"sub/root.txt.tpl", false, ""},
+		{"build/out.tpl", true, "build/"},
+		{"src/build/out.tpl", true, "build/"},
+		{"build", false, ""},
+		{"docs/a/b/draft1.md.tpl", true, "docs/**/draft*"},
+		{"docs/draft.md.tpl", true, "docs/**/draft*"},
+		{"secret.tpl", true, "secret*"},
+		{"secret_keep.tpl", false, "!secret_keep.tpl"},
+		{"vendor/keep.tpl", true, "vendor/"},
+		{"#hash.tpl", true, `\#hash.tpl`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ignored, rule := rules.Match(tt.path)
+			if ignored != tt.wantIgnored {
+				t.Errorf("Match(%q) ignored = %v, want %v", tt.path, ignored, tt.wantIgnored)
+			}
+			if rule != tt.wantRule {
+				t.Errorf("Match(%q) rule = %q, want %q", tt.path, rule, tt.wantRule)
+			}
+		})
+	}
+
+	var nilRules *IgnoreRules
+	if ignored, _ := nilRules.Match("a.tpl"); ignored {
+		t.Error("nil IgnoreRules should not ignore anything")
+	}
+}
+
+func TestParseIgnoreRules_Invalid(t *testing.T) {
+	if _, err := ParseIgnoreRules([]string{"[abc"}); err == nil {
+		t.Error("ParseIgnoreRules() expected error for invalid pattern, got nil")
+	}
+}
+
+func TestPatternTemplateFilter_ShouldInclude(t *testing.T) {
+	ignore, err := ParseIgnoreRules([]string{"*.draft.tpl"})
+	if err != nil {
+		t.Fatalf("ParseIgnoreRules() error = %v", err)
+	}
+	base := NewDefaultTemplateFilter(true, "", "legacy", "/templates")
+
+	filter, err := NewPatternTemplateFilter(base, []string{"server/**", "README.md.tpl"}, []string{"**/*_test.go.tpl"}, ignore)
+	if err != nil {
+		t.Fatalf("NewPatternTemplateFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		relativePath string
+		wantInclude  bool
+		wantReason   string
+	}{
+		{"included by pattern", "server/main.go.tpl", true, ""},
+		{"included top level", "README.md.tpl", true, ""},
+		{"not included", "web/index.html.tpl", false, "不匹配任何包含模式"},
+		{"excluded", "server/api/handler_test.go.tpl", false, "排除模式匹配: **/*_test.go.tpl"},
+		{"ignored by genignore", "server/notes.draft.tpl", false, ".genignore 匹配: *.draft.tpl"},
+		{"base filter first", "legacy/server/main.go.tpl", false, "前缀匹配: legacy"},
+		{"child template", "server/__child__/part.tpl", false, "子模板"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join("/templates", tt.relativePath)
+			gotInclude, gotReason := filter.ShouldInclude(path, tt.relativePath)
+			if gotInclude != tt.wantInclude {
+				t.Errorf("ShouldInclude() include = %v, want %v", gotInclude, tt.wantInclude)
+			}
+			if gotReason != tt.wantReason {
+				t.Errorf("ShouldInclude() reason = %v, want %v", gotReason, tt.wantReason)
+			}
+		})
+	}
+
+	if _, err := NewPatternTemplateFilter(nil, []string{"[bad"}, nil, nil); err == nil {
+		t.Error("NewPatternTemplateFilter() expected error for invalid pattern, got nil")
+	}
+}
+
+func TestControlFileFilter_ShouldInclude(t *testing.T) {
+	tests := []struct {
+		relativePath string
+		wantInclude  bool
+		wantReason   string
+	}{
+		{".genignore", false, "忽略规则文件"},
+		{".gen_schema.yaml", false, "变量校验规则文件"},
+		{".gen_questions.yaml", false, "问题文件"},
+		{".gen_when", false, "条件文件"},
+		{"auth/.gen_when", false, "条件文件"},
+		// 只有模板目录根部的规则文件是控制文件
+		{"docs/.gen_schema.yaml", true, ""},
+		{"main.go.tpl", true, ""},
+	}
+	for _, tt := range tests {
+		gotInclude, gotReason := ControlFileFilter{}.ShouldInclude(filepath.Join("/templates", tt.relativePath), tt.relativePath)
+		if gotInclude != tt.wantInclude || gotReason != tt.wantReason {
+			t.Errorf("ShouldInclude(%s) = %v, %q, want %v, %q", tt.relativePath, gotInclude, gotReason, tt.wantInclude, tt.wantReason)
+		}
+	}
+}
+
+// includeAllFilter 包含所有文件的自定义过滤器
+type includeAllFilter struct{}
+
+func (includeAllFilter) ShouldInclude(path, relativePath string) (bool, string) {
+	return true, ""
+}
+
+func TestGenerateFiles_ControlFilesWithCustomFilter(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "control_files_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/main.go.tpl":         "{{ .name }}",
+		"templates/.genignore":          "*.draft.tpl\n",
+		"templates/.gen_schema.yaml":    "type: object\n",
+		"templates/.gen_questions.yaml": "questions:\n  - name: name\n",
+		"templates/.gen_when":           "main.go.tpl: true\n",
+		"templates/docs/.gen_when":      "true",
+		"templates/docs/index.md.tpl":   "docs",
+		"variables/variables.yaml":      "name: demo",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+	}
+
+	// 自定义过滤器不排除控制文件，它们仍然不能作为模板生成
+	generated, err := NewGenerator().WithTemplateFilter(includeAllFilter{}).GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	var got []string
+	for _, file := range generated {
+		rel, _ := filepath.Rel(cfg.OutputDir, file.OutputPath)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	want := []string{"docs/index.md", "main.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GenerateFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestGenerateFiles_IncludeExcludeAndGenignore(t *testing.T) {
+	rootDir, err := os.MkdirTemp("", "generator-pattern-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(rootDir)
+
+	templateDir := filepath.Join(rootDir, "templates")
+	variableDir := filepath.Join(rootDir, "variables")
+	outputDir := filepath.Join(rootDir, "output")
+
+	files := map[string]string{
+		filepath.Join(templateDir, IgnoreFileName):                 "tmp/\n",
+		filepath.Join(templateDir, "server", "main.go.tpl"):        "main",
+		filepath.Join(templateDir, "server", "main_test.go.tpl"):   "test",
+		filepath.Join(templateDir, "server", "tmp", "scratch.tpl"): "scratch",
+		filepath.Join(templateDir, "web", "index.html.tpl"):        "web",
+		filepath.Join(variableDir, "variables.yaml"):               "key: value",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file %s: %v", path, err)
+		}
+	}
+
+	g := NewGenerator()
+	generated, err := g.GenerateFiles(&config.Config{
+		TemplateDir:  templateDir,
+		VariablesDir: variableDir,
+		OutputDir:    outputDir,
+		Include:      []string{"server/**"},
+		Exclude:      []string{"**/*_test.go.tpl"},
+	})
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+
+	if len(generated) != 1 || generated[0].OutputPath != filepath.Join(outputDir, "server", "main.go") {
+		t.Errorf("GenerateFiles() = %+v, want only server/main.go", generated)
+	}
+}
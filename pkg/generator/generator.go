@@ -2,10 +2,16 @@ package generator
 
 import (
 	"log"
+	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/clh021/generator/internal/template"
 	"github.com/clh021/generator/pkg/config"
+	"github.com/clh021/generator/pkg/sandbox"
+	"github.com/clh021/generator/pkg/variables"
 
 	"github.com/pkg/errors"
 )
@@ -18,6 +24,14 @@ type Generator struct {
 	pathProcessor    PathProcessor
 	contentGenerator ContentGenerator
 	templateFilter   TemplateFilter
+	outputWriter     OutputWriter
+	config           *config.Config
+	// 命令行变量覆盖，在所有变量文件之后应用
+	overrides []variables.Override
+	// 询问缺少的变量，为 nil 时为非交互模式
+	prompter Prompter
+	// 最近一次加载变量时确定的机密变量
+	secrets *variables.Secrets
 }
 
 // NewGenerator 创建新的生成器实例
@@ -29,6 +43,7 @@ func NewGenerator() *Generator {
 		pathProcessor:    NewDefaultPathProcessor(),
 		contentGenerator: NewDefaultContentGenerator(),
 		templateFilter:   nil, // 将在 GenerateFiles 中初始化
+		outputWriter:     nil, // 将在 GenerateFiles 中初始化
 	}
 }
 
@@ -62,44 +77,220 @@ func (g *Generator) WithTemplateFilter(filter TemplateFilter) *Generator {
 	return g
 }
 
+// WithOutputWriter 设置输出写入器
+func (g *Generator) WithOutputWriter(writer OutputWriter) *Generator {
+	g.outputWriter = writer
+	return g
+}
+
+// WithVariableOverrides 设置变量覆盖（-set 等），按顺序深度合并到加载的变量之上
+func (g *Generator) WithVariableOverrides(overrides []variables.Override) *Generator {
+	g.overrides = overrides
+	return g
+}
+
+// WithPrompter 设置询问器，用于交互输入问题文件中声明但没有提供的变量
+func (g *Generator) WithPrompter(prompter Prompter) *Generator {
+	g.prompter = prompter
+	return g
+}
+
 // GenerateFiles 执行生成过程但不写入文件，而是返回生成的文件列表
 func (g *Generator) GenerateFiles(cfg *config.Config) ([]GeneratedFile, error) {
 	var generatedFiles []GeneratedFile
 
+	mergeOptions, err := g.prepare(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// 初始化模板过滤器（如果未设置）
+	if g.templateFilter == nil {
+		filter, err := NewTemplateFilterForDir(cfg.TemplateDir, cfg.SkipTemplateSuffixes, cfg.SkipTemplatePrefixes, cfg.Include, cfg.Exclude)
+		if err != nil {
+			return nil, errors.Wrap(err, "创建模板过滤器失败")
+		}
+		g.templateFilter = filter
+	}
+
+	// 严格模式下路径中的变量无法替换时生成失败
+	if processor, ok := g.pathProcessor.(*DefaultPathProcessor); ok && cfg.StrictPathVariables {
+		processor.Strict = true
+	}
+
+	// 在加载变量和渲染模板之前校验按模板匹配的配置规则
+	if err := validateWritePolicyRules(cfg.WritePolicies); err != nil {
+		return nil, errors.Wrap(err, "写入策略配置无效")
+	}
+	if err := validateEachRules(cfg.Each); err != nil {
+		return nil, errors.Wrap(err, "each 配置无效")
+	}
+	for _, pattern := range cfg.AllowOutsideOutput {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, errors.Errorf("allow_outside_output 中的匹配模式无效: %s", pattern)
+		}
+	}
+	for _, pattern := range cfg.SkipEmptyTemplates {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, errors.Errorf("skip_empty_templates 中的匹配模式无效: %s", pattern)
+		}
+	}
+
+	// 初始化输出写入器（如果未设置）
+	if g.outputWriter == nil {
+		policy, err := ParseWritePolicy(cfg.WritePolicy)
+		if err != nil {
+			return nil, errors.Wrap(err, "写入策略配置无效")
+		}
+		writer := NewFileSystemWriter(policy)
+		writer.AllowOrphanedRegions = cfg.AllowOrphanedRegions
+		g.outputWriter = writer
+	}
+
+	engine, err := g.loadVariables(cfg, mergeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// 扫描模板
+	templateFiles, err := g.templateScanner.ScanTemplates(cfg.TemplateDir, g.templateFilter)
+	if err != nil {
+		return nil, errors.Wrap(err, "扫描模板失败")
+	}
+	// 控制文件不是模板，不依赖于模板过滤器的设置
+	templateFiles = filterTemplates(templateFiles, ControlFileFilter{})
+
+	// 按 .gen_when 中的条件跳过模板，被跳过的模板不会渲染
+	conditions, err := LoadConditionFilter(cfg.TemplateDir, engine)
+	if err != nil {
+		return nil, errors.Wrap(err, "加载生成条件失败")
+	}
+	templateFiles = filterTemplates(templateFiles, conditions)
+
+	// 并发处理模板文件
+	generatedFiles, err = g.renderTemplates(templateFiles, cfg, engine)
+	if err != nil {
+		return nil, err
+	}
+
+	// 为使用 merge 策略的文件加载上次生成的内容
+	if !cfg.DisableManifest {
+		if err := g.loadBases(generatedFiles, cfg); err != nil {
+			return nil, errors.Wrap(err, "加载上次生成的内容失败")
+		}
+	}
+
+	return generatedFiles, nil
+}
+
+// Variables 按与 GenerateFiles 相同的方式加载并校验变量，返回合并后的变量以及每个值的来源
+func (g *Generator) Variables(cfg *config.Config) (map[string]interface{}, *variables.Provenance, error) {
+	mergeOptions, err := g.prepare(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	engine, err := g.loadVariables(cfg, mergeOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return engine.GetVariables(), engine.Provenance(), nil
+}
+
+// Secrets 返回最近一次加载变量（GenerateFiles 或 Variables）时确定的机密变量，尚未加载时返回 nil
+// 输出变量或诊断信息前可用 Secrets.Redact 隐藏机密的值
+func (g *Generator) Secrets() *variables.Secrets {
+	return g.secrets
+}
+
+// prepare 将配置中的目录转换为绝对路径并初始化变量加载器，返回变量合并选项
+func (g *Generator) prepare(cfg *config.Config) (variables.MergeOptions, error) {
+	var mergeOptions variables.MergeOptions
+
 	// 确保所有路径都是绝对路径
 	var err error
 	cfg.TemplateDir, err = filepath.Abs(cfg.TemplateDir)
 	if err != nil {
-		return nil, errors.Wrapf(err, "无法获取模板目录的绝对路径: %s", cfg.TemplateDir)
+		return mergeOptions, errors.Wrapf(err, "无法获取模板目录的绝对路径: %s", cfg.TemplateDir)
 	}
 	cfg.VariablesDir, err = filepath.Abs(cfg.VariablesDir)
 	if err != nil {
-		return nil, errors.Wrapf(err, "无法获取变量目录的绝对路径: %s", cfg.VariablesDir)
+		return mergeOptions, errors.Wrapf(err, "无法获取变量目录的绝对路径: %s", cfg.VariablesDir)
 	}
 	cfg.OutputDir, err = filepath.Abs(cfg.OutputDir)
 	if err != nil {
-		return nil, errors.Wrapf(err, "无法获取输出目录的绝对路径: %s", cfg.OutputDir)
+		return mergeOptions, errors.Wrapf(err, "无法获取输出目录的绝对路径: %s", cfg.OutputDir)
 	}
+	g.config = cfg
 
-	// 初始化变量加载器（如果未设置）
-	if g.variableLoader == nil {
-		g.variableLoader = NewDefaultVariableLoader(cfg.TemplateDir, cfg.VariablesDir, cfg.OutputDir)
+	// 变量合并选项
+	mergeOptions, err = variables.NewMergeOptions(cfg.ListMerge, cfg.ListMergePaths)
+	if err != nil {
+		return mergeOptions, errors.Wrap(err, "列表合并策略配置无效")
 	}
 
-	// 初始化模板过滤器（如果未设置）
-	if g.templateFilter == nil {
-		g.templateFilter = NewDefaultTemplateFilter(true, cfg.SkipTemplateSuffixes, cfg.SkipTemplatePrefixes, cfg.TemplateDir)
+	// 初始化变量加载器（如果未设置）
+	if g.variableLoader == nil {
+		loader := NewDefaultVariableLoader(cfg.TemplateDir, cfg.VariablesDir, cfg.OutputDir)
+		loader.MergeOptions = mergeOptions
+		loader.Profiles = cfg.Profiles
+		g.variableLoader = loader
 	}
+	return mergeOptions, nil
+}
 
+// loadVariables 按 变量文件 < 环境变量 < 命令行变量覆盖 < 交互输入 < 校验规则默认值 的顺序加载变量，
+// 设置保留变量 __profile 和 __profiles，计算并校验后返回加载了变量的模板引擎，引擎同时记录每个变量值的来源
+func (g *Generator) loadVariables(cfg *config.Config, mergeOptions variables.MergeOptions) (*template.Engine, error) {
 	// 加载变量
-	variables, err := g.variableLoader.LoadVariables(cfg.VariablesDir, cfg.VariableFiles)
+	vars, err := g.variableLoader.LoadVariables(cfg.VariablesDir, cfg.VariableFiles)
 	if err != nil {
 		return nil, errors.Wrap(err, "加载变量失败")
 	}
-	g.variables = variables
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	// 环境变量覆盖变量文件，命令行变量覆盖（-set 等）的优先级最高
+	var envVars map[string]interface{}
+	var envProvenance *variables.Provenance
+	if cfg.VariableEnvPrefix != "" {
+		envVars, envProvenance, err = NewEnvVariableSource(cfg.VariableEnvPrefix).LoadVariablesWithProvenance()
+		if err != nil {
+			return nil, errors.Wrap(err, "从环境变量加载变量失败")
+		}
+		vars = variables.Merge(vars, envVars, mergeOptions)
+	}
+	if err := variables.ApplyOverrides(vars, g.overrides, mergeOptions); err != nil {
+		return nil, errors.Wrap(err, "应用变量覆盖失败")
+	}
 
-	// 创建模板引擎
-	engine := template.New(cfg.TemplateDir, cfg.VariablesDir, cfg.OutputDir)
+	// 询问问题文件中声明但仍然缺少的变量
+	questionsFile := cfg.QuestionsFile
+	if questionsFile == "" {
+		questionsFile = FindQuestionsFile(cfg.TemplateDir)
+	}
+	var answers map[string]interface{}
+	if questionsFile != "" {
+		questions, err := LoadQuestions(questionsFile)
+		if err != nil {
+			return nil, err
+		}
+		if answers, err = askQuestions(questions, vars, g.prompter); err != nil {
+			return nil, err
+		}
+		vars = variables.Merge(vars, answers, mergeOptions)
+		if cfg.SaveAnswers && len(answers) > 0 {
+			path, err := saveAnswers(cfg.VariablesDir, answers)
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("回答已保存到 %s", path)
+		}
+	}
+	// 当前的变量配置
+	profileVars := profileVariables(cfg.Profiles)
+	for k, v := range profileVars {
+		vars[k] = v
+	}
 
 	// 加载变量文件
 	variableFiles, err := g.variableLoader.FindVariableFiles(cfg.VariablesDir, cfg.VariableFiles)
@@ -107,40 +298,328 @@ func (g *Generator) GenerateFiles(cfg *config.Config) ([]GeneratedFile, error) {
 		return nil, errors.Wrap(err, "查找变量文件失败")
 	}
 
+	// 创建模板引擎
+	engine := template.New(cfg.TemplateDir, cfg.VariablesDir, cfg.OutputDir).WithMergeOptions(mergeOptions)
+	if cfg.Sandbox {
+		roots := append([]string{cfg.TemplateDir, cfg.VariablesDir}, cfg.SandboxRoots...)
+		s, err := sandbox.New(roots...)
+		if err != nil {
+			return nil, errors.Wrap(err, "创建沙箱失败")
+		}
+		engine.WithSandbox(s)
+	}
+
 	// 加载变量到引擎
 	if err := engine.LoadVariables(variableFiles); err != nil {
 		return nil, errors.Wrap(err, "加载变量到引擎失败")
 	}
+	engine.MergeVariables(envVars, envProvenance)
+	if err := engine.ApplyOverrides(g.overrides); err != nil {
+		return nil, errors.Wrap(err, "应用变量覆盖失败")
+	}
+	engine.MergeVariables(answers, variables.ProvenanceOf(answers, variables.Origin{File: "交互输入"}))
+	engine.MergeVariables(profileVars, variables.ProvenanceOf(profileVars, variables.Origin{File: "变量配置"}))
 
-	// 扫描模板
-	templateFiles, err := g.templateScanner.ScanTemplates(cfg.TemplateDir, g.templateFilter)
+	// 渲染前按校验规则设置默认值
+	schemaFile := cfg.SchemaFile
+	if schemaFile == "" {
+		schemaFile = FindSchemaFile(cfg.TemplateDir)
+	}
+	var schema *variables.Schema
+	if schemaFile != "" {
+		if schema, err = variables.LoadSchema(schemaFile); err != nil {
+			return nil, err
+		}
+		for _, path := range schema.ApplyDefaults(engine.GetVariables()) {
+			if err := engine.RecordOrigin(path, variables.Origin{File: "默认值 " + schemaFile}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 展开变量引用并计算 computed 中的变量，只计算一次，
+	// 输出路径、each、.gen_when 和模板内容使用同一份计算后的变量
+	if err := engine.ResolveVariables(); err != nil {
+		return nil, errors.Wrap(err, "计算变量失败")
+	}
+	g.variables = engine.GetVariables()
+
+	// 机密变量：键名匹配 secret_keys、变量文件中带有 !secret 标签或校验规则中声明了 secret: true
+	secretKeys := cfg.SecretKeys
+	if secretKeys == nil {
+		secretKeys = variables.DefaultSecretKeys
+	}
+	secrets, err := variables.NewSecrets(secretKeys, engine.Provenance().Secrets()...)
 	if err != nil {
-		return nil, errors.Wrap(err, "扫描模板失败")
+		return nil, err
+	}
+	if schema != nil {
+		secrets.Mark(schema.SecretPaths(engine.GetVariables())...)
+	}
+	engine.WithSecrets(secrets)
+	g.secrets = secrets
+
+	// 校验最终的变量
+	if schema != nil {
+		if err := validateVariables(schema, engine.GetVariables(), engine.Provenance(), secrets); err != nil {
+			return nil, err
+		}
+	}
+	return engine, nil
+}
+
+// renderTemplates 使用有界的工作池并发渲染模板，结果保持模板扫描顺序
+// 所有模板处理完成后返回汇总的错误（*MultiError）
+// cfg.Jobs > 1 时路径处理器和内容生成器会被并发调用
+func (g *Generator) renderTemplates(templateFiles []TemplateFile, cfg *config.Config, engine *template.Engine) ([]GeneratedFile, error) {
+	if len(templateFiles) == 0 {
+		return nil, nil
+	}
+
+	jobs := cfg.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	if jobs > len(templateFiles) {
+		jobs = len(templateFiles)
+	}
+
+	rendered := make([][]GeneratedFile, len(templateFiles))
+	errs := make([]error, len(templateFiles))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				rendered[i], errs[i] = g.renderTemplate(templateFiles[i], cfg, engine)
+			}
+		}()
+	}
+	for i := range templateFiles {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return nil, &MultiError{Errors: failed}
+	}
+
+	var files []GeneratedFile
+	for _, r := range rendered {
+		files = append(files, r...)
+	}
+	if err := checkOutputCollisions(files, cfg.TemplateDir); err != nil {
+		return nil, err
 	}
+	return files, nil
+}
 
-	// 处理每个模板文件
-	for _, templateFile := range templateFiles {
-		// 处理输出路径
-		outputPath, err := g.pathProcessor.ProcessOutputPath(templateFile, cfg.OutputDir, g.variables)
+// renderTemplate 处理单个模板文件
+// 模板匹配 each 规则时对列表中的每一项各生成一个文件，列表项及其下标加入模板和输出路径的变量中
+func (g *Generator) renderTemplate(templateFile TemplateFile, cfg *config.Config, engine *template.Engine) ([]GeneratedFile, error) {
+	source, ok := matchEach(cfg.Each, templateFile.RelativePath)
+	if !ok {
+		file, err := g.renderFile(templateFile, cfg, engine, g.variables)
 		if err != nil {
-			log.Printf("警告: 处理输出路径失败: %v, 使用默认路径", err)
+			return nil, err
 		}
+		return []GeneratedFile{file}, nil
+	}
 
-		// 生成文件内容
-		content, err := g.contentGenerator.GenerateContent(templateFile, outputPath, engine)
+	items, err := source.Items(g.variables)
+	if err != nil {
+		return nil, errors.Wrapf(err, "按列表生成失败 (%s)", templateFile.Path)
+	}
+	if len(items) == 0 {
+		log.Printf("列表 %s 为空，模板 %s 不生成文件", source.List, templateFile.RelativePath)
+	}
+	files := make([]GeneratedFile, 0, len(items))
+	for i, item := range items {
+		data := map[string]interface{}{
+			source.Name:        item,
+			source.IndexName(): i,
+		}
+		pathVars := make(map[string]interface{}, len(g.variables)+len(data))
+		for k, v := range g.variables {
+			pathVars[k] = v
+		}
+		for k, v := range data {
+			pathVars[k] = v
+		}
+
+		itemPath := variables.IndexPath(source.List, i)
+		file, err := g.renderFile(templateFile, cfg, engine.Scope(data), pathVars)
 		if err != nil {
-			return nil, errors.Wrapf(err, "生成内容失败 (%s)", templateFile.Path)
+			return nil, errors.Wrapf(err, "列表项 %s", itemPath)
 		}
+		file.Item = itemPath
+		files = append(files, file)
+	}
+	return files, nil
+}
 
-		// 添加到生成的文件列表
-		generatedFiles = append(generatedFiles, GeneratedFile{
-			TemplatePath: templateFile.Path,
-			OutputPath:   outputPath,
-			Content:      content,
-		})
+// renderFile 使用给定的路径变量和模板引擎生成一个文件
+func (g *Generator) renderFile(templateFile TemplateFile, cfg *config.Config, engine *template.Engine, pathVars map[string]interface{}) (GeneratedFile, error) {
+	// 处理输出路径
+	outputPath, err := g.pathProcessor.ProcessOutputPath(templateFile, cfg.OutputDir, pathVars)
+	if err != nil {
+		var varErr *PathVariableError
+		if errors.As(err, &varErr) {
+			return GeneratedFile{}, errors.Wrapf(err, "处理输出路径失败 (%s)", templateFile.Path)
+		}
+		log.Printf("警告: 处理输出路径失败: %v, 使用默认路径", err)
 	}
 
-	return generatedFiles, nil
+	// 输出路径不能超出输出目录，除非模板匹配 allow_outside_output
+	if err := checkOutputPath(templateFile, outputPath, cfg.OutputDir, pathVars); err != nil {
+		var pathErr *OutputPathError
+		if !errors.As(err, &pathErr) || !matchAny(cfg.AllowOutsideOutput, templateFile.RelativePath) {
+			return GeneratedFile{}, err
+		}
+		log.Printf("警告: 模板 %s 的输出路径 %s 位于输出目录之外（已通过 allow_outside_output 允许）", templateFile.RelativePath, outputPath)
+	}
+
+	// 生成文件内容
+	content, err := g.contentGenerator.GenerateContent(templateFile, outputPath, engine)
+	if err != nil {
+		return GeneratedFile{}, errors.Wrapf(err, "生成内容失败 (%s)", templateFile.Path)
+	}
+
+	return GeneratedFile{
+		TemplatePath: templateFile.Path,
+		OutputPath:   outputPath,
+		Content:      content,
+		WritePolicy:  matchWritePolicy(cfg.WritePolicies, templateFile.RelativePath),
+		SkipEmpty:    cfg.SkipEmpty || matchAny(cfg.SkipEmptyTemplates, templateFile.RelativePath),
+	}, nil
+}
+
+// loadBases 从生成清单中读取上次的渲染结果，作为 merge 策略三方合并的共同祖先
+func (g *Generator) loadBases(files []GeneratedFile, cfg *config.Config) error {
+	manifest, err := LoadManifest(cfg.OutputDir)
+	if err != nil || manifest == nil {
+		return err
+	}
+
+	for i, file := range files {
+		if effectivePolicy(file, WritePolicy(cfg.WritePolicy)) != WritePolicyMerge {
+			continue
+		}
+
+		base, ok, err := manifest.LoadRender(cfg.OutputDir, manifestPath(cfg.OutputDir, file.OutputPath))
+		if err != nil {
+			return err
+		}
+		if ok {
+			files[i].Base = &base
+		}
+	}
+	return nil
+}
+
+// WriteFiles 使用输出写入器写入生成的文件，返回每个文件的写入结果
+// 遇到错误时停止，并返回已完成的结果
+// 内容只包含空白且启用了 SkipEmpty 的文件不写入，上次生成的同名文件未被修改时将其删除，见 GeneratedFile.Dropped
+// 全部写入成功后在输出目录中更新生成清单（除非配置中禁用）
+func (g *Generator) WriteFiles(files []GeneratedFile) ([]WriteResult, error) {
+	if g.outputWriter == nil {
+		g.outputWriter = NewFileSystemWriter(WritePolicyOverwrite)
+	}
+
+	useManifest := g.config != nil && !g.config.DisableManifest
+	var previous *Manifest
+	if useManifest {
+		var err error
+		if previous, err = LoadManifest(g.config.OutputDir); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []WriteResult
+	for _, file := range files {
+		if file.Dropped() {
+			result, err := dropFile(file, previous, g.config)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, result)
+			continue
+		}
+		result, err := g.outputWriter.WriteFile(file)
+		if err != nil {
+			return results, errors.Wrapf(err, "写入文件失败 (%s)", file.OutputPath)
+		}
+		results = append(results, result)
+	}
+
+	if useManifest {
+		manifest, err := buildManifest(g.config.TemplateDir, g.config.OutputDir, files, results, previous, WritePolicy(g.config.WritePolicy))
+		if err != nil {
+			return results, err
+		}
+		if err := manifest.Save(g.config.OutputDir); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// dropFile 处理内容只包含空白的文件：不写入，并删除清单中记录的上次生成的文件
+// 文件在生成后被修改过时保留，previous 为 nil 时只跳过写入
+func dropFile(file GeneratedFile, previous *Manifest, cfg *config.Config) (WriteResult, error) {
+	result := WriteResult{OutputPath: file.OutputPath, Action: WriteActionDropped}
+	if previous == nil {
+		return result, nil
+	}
+	entry, ok := previous.Entry(manifestPath(cfg.OutputDir, file.OutputPath))
+	if !ok {
+		return result, nil
+	}
+
+	// 只删除输出目录中的文件
+	path, err := resolveManifestPath(cfg.OutputDir, entry.Path)
+	if err != nil {
+		return result, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return result, errors.Wrapf(err, "读取上次生成的文件失败: %s", path)
+	}
+	if ContentHash(string(data)) != entry.Hash {
+		result.Action = WriteActionKeptModified
+		result.Hash = ContentHash(string(data))
+		return result, nil
+	}
+
+	if err := os.Remove(path); err != nil {
+		return result, errors.Wrapf(err, "删除上次生成的文件失败: %s", path)
+	}
+	removeEmptyParents(filepath.Dir(path), cfg.OutputDir)
+	result.Action = WriteActionRemoved
+	return result, nil
+}
+
+// Prune 删除上次生成清单中记录、但不在 files 中的过期文件
+// 生成后被修改过的文件不会删除，除非 force 为 true；dryRun 为 true 时只报告不删除
+func (g *Generator) Prune(files []GeneratedFile, force, dryRun bool) ([]PruneResult, error) {
+	if g.config == nil {
+		return nil, errors.New("清理过期文件前需要先调用 GenerateFiles")
+	}
+	return PruneStaleFiles(g.config.OutputDir, files, force, dryRun)
 }
 
 // 以下函数已移至各自的文件中，这里保留注释以便于理解代码结构
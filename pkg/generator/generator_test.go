@@ -60,7 +60,7 @@ func TestDefaultPathProcessor_ProcessTemplatePath(t *testing.T) {
 			name:     "non-string variable",
 			path:     "path/to/__number__.txt",
 			vars:     map[string]interface{}{"number": 123},
-			expected: "path/to/__number__.txt",
+			expected: "path/to/123.txt",
 			wantErr:  false,
 		},
 		{
@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/pkg/errors"
+)
+
+// IgnoreFileName 模板目录中的忽略规则文件名，语法与 .gitignore 相同
+const IgnoreFileName = ".genignore"
+
+// ignoreRule 表示 .genignore 中的一条规则
+type ignoreRule struct {
+	pattern string // 转换后的 doublestar 模式
+	raw     string // 原始规则，用于报告排除原因
+	negate  bool   // 以 ! 开头的规则，重新包含匹配的路径
+	dirOnly bool   // 以 / 结尾的规则，只匹配目录
+}
+
+// IgnoreRules 表示一组 gitignore 语法的忽略规则
+type IgnoreRules struct {
+	rules []ignoreRule
+}
+
+// LoadIgnoreFile 读取忽略规则文件，文件不存在时返回 nil
+func LoadIgnoreFile(path string) (*IgnoreRules, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取忽略规则文件失败: %s", path)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "读取忽略规则文件失败: %s", path)
+	}
+
+	rules, err := ParseIgnoreRules(lines)
+	if err != nil {
+		return nil, errors.Wrapf(err, "解析忽略规则文件失败: %s", path)
+	}
+	return rules, nil
+}
+
+// ParseIgnoreRules 解析 gitignore 语法的规则行
+func ParseIgnoreRules(lines []string) (*IgnoreRules, error) {
+	rules := &IgnoreRules{}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{raw: line}
+		pattern := line
+
+		// 处理取反与转义
+		if strings.HasPrefix(pattern, "!") {
+			rule.negate = true
+			pattern = pattern[1:]
+		} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+			pattern = pattern[1:]
+		}
+
+		if strings.HasSuffix(pattern, "/") {
+			rule.dirOnly = true
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+
+		// 包含 / 的模式相对于模板目录根部匹配，否则匹配任意层级
+		if strings.Contains(pattern, "/") {
+			pattern = strings.TrimPrefix(pattern, "/")
+		} else {
+			pattern = "**/" + pattern
+		}
+
+		if pattern == "" || !doublestar.ValidatePattern(pattern) {
+			return nil, errors.Errorf("无效的忽略规则: %s", line)
+		}
+
+		rule.pattern = pattern
+		rules.rules = append(rules.rules, rule)
+	}
+	return rules, nil
+}
+
+// Match 检查相对于模板目录的文件路径是否被忽略，返回是否忽略以及匹配的规则
+// 与 gitignore 相同，被忽略目录下的文件无法通过取反规则重新包含
+func (r *IgnoreRules) Match(relativePath string) (bool, string) {
+	if r == nil {
+		return false, ""
+	}
+
+	parts := strings.Split(filepath.ToSlash(relativePath), "/")
+	for i := 1; i < len(parts); i++ {
+		if ignored, rule := r.match(strings.Join(parts[:i], "/"), true); ignored {
+			return true, rule
+		}
+	}
+	return r.match(strings.Join(parts, "/"), false)
+}
+
+// match 按顺序应用所有规则，最后一条匹配的规则决定结果
+func (r *IgnoreRules) match(path string, isDir bool) (bool, string) {
+	ignored := false
+	matched := ""
+	for _, rule := range r.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if ok, _ := doublestar.Match(rule.pattern, path); ok {
+			ignored = !rule.negate
+			matched = rule.raw
+		}
+	}
+	return ignored, matched
+}
@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// 保留区域标记，可以放在任意注释语法中，例如:
+//
+//	// gen:keep begin handler-body
+//	...开发者编写的代码...
+//	// gen:keep end
+var (
+	keepBeginPattern = regexp.MustCompile(`gen:keep\s+begin\s+([A-Za-z0-9_.:/-]+)`)
+	keepEndPattern   = regexp.MustCompile(`gen:keep\s+end\b`)
+)
+
+// KeptRegion 表示文件中由保留标记包围的一段内容
+type KeptRegion struct {
+	// 区域名称
+	Name string
+	// 标记之间的内容（不含标记行）
+	Body string
+	// begin 标记所在行号，从 1 开始
+	Line int
+}
+
+// OrphanedRegionsError 表示现有文件中的保留区域在新生成的内容中已不存在
+type OrphanedRegionsError struct {
+	Path    string
+	Regions []KeptRegion
+}
+
+func (e *OrphanedRegionsError) Error() string {
+	var names []string
+	for _, region := range e.Regions {
+		names = append(names, fmt.Sprintf("%s (第 %d 行)", region.Name, region.Line))
+	}
+	return fmt.Sprintf("文件 %s 中的保留区域在模板中已不存在，继续写入将丢失其内容: %s",
+		e.Path, strings.Join(names, ", "))
+}
+
+// parseKeptRegions 解析内容中的所有保留区域
+func parseKeptRegions(content string) ([]KeptRegion, error) {
+	var regions []KeptRegion
+	seen := make(map[string]int)
+
+	var current *KeptRegion
+	var body strings.Builder
+	for i, line := range splitLines(content) {
+		lineNo := i + 1
+		if match := keepBeginPattern.FindStringSubmatch(line); match != nil {
+			if current != nil {
+				return nil, errors.Errorf("第 %d 行: 保留区域 %s 未结束就开始了新的区域 %s", lineNo, current.Name, match[1])
+			}
+			if prev, ok := seen[match[1]]; ok {
+				return nil, errors.Errorf("第 %d 行: 保留区域 %s 重复定义（首次定义于第 %d 行）", lineNo, match[1], prev)
+			}
+			seen[match[1]] = lineNo
+			current = &KeptRegion{Name: match[1], Line: lineNo}
+			body.Reset()
+			continue
+		}
+
+		if keepEndPattern.MatchString(line) {
+			if current == nil {
+				return nil, errors.Errorf("第 %d 行: 保留区域结束标记没有对应的开始标记", lineNo)
+			}
+			current.Body = body.String()
+			regions = append(regions, *current)
+			current = nil
+			continue
+		}
+
+		if current != nil {
+			body.WriteString(line)
+		}
+	}
+
+	if current != nil {
+		return nil, errors.Errorf("第 %d 行: 保留区域 %s 没有结束标记", current.Line, current.Name)
+	}
+	return regions, nil
+}
+
+// MergeKeptRegions 将现有文件内容中的保留区域注入新生成的内容
+// 返回合并后的内容，以及在新内容中已不存在的孤立区域
+func MergeKeptRegions(rendered, existing string) (string, []KeptRegion, error) {
+	existingRegions, err := parseKeptRegions(existing)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "解析现有文件的保留区域失败")
+	}
+	if len(existingRegions) == 0 {
+		return rendered, nil, nil
+	}
+
+	// 校验新内容的标记
+	renderedRegions, err := parseKeptRegions(rendered)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "解析生成内容的保留区域失败")
+	}
+
+	bodies := make(map[string]string, len(existingRegions))
+	for _, region := range existingRegions {
+		bodies[region.Name] = region.Body
+	}
+
+	inRendered := make(map[string]bool, len(renderedRegions))
+	for _, region := range renderedRegions {
+		inRendered[region.Name] = true
+	}
+	var orphaned []KeptRegion
+	for _, region := range existingRegions {
+		if !inRendered[region.Name] {
+			orphaned = append(orphaned, region)
+		}
+	}
+
+	var sb strings.Builder
+	skipping := false
+	for _, line := range splitLines(rendered) {
+		if skipping {
+			if !keepEndPattern.MatchString(line) {
+				continue
+			}
+			skipping = false
+		}
+
+		sb.WriteString(line)
+
+		if match := keepBeginPattern.FindStringSubmatch(line); match != nil {
+			if body, ok := bodies[match[1]]; ok {
+				// 标记行缺少换行符时补上，避免与区域内容粘连
+				if !strings.HasSuffix(line, "\n") {
+					sb.WriteString("\n")
+				}
+				sb.WriteString(body)
+				skipping = true
+			}
+		}
+	}
+
+	return sb.String(), orphaned, nil
+}
+
+// MergeKeptRegions 返回注入了现有内容中保留区域的文件副本
+func (f GeneratedFile) MergeKeptRegions(existing string) (GeneratedFile, []KeptRegion, error) {
+	merged, orphaned, err := MergeKeptRegions(f.Content, existing)
+	if err != nil {
+		return f, nil, errors.Wrapf(err, "合并保留区域失败: %s", f.OutputPath)
+	}
+	f.Content = merged
+	return f, orphaned, nil
+}
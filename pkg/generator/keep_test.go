@@ -0,0 +1,155 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestMergeKeptRegions(t *testing.T) {
+	rendered := `package main
+
+func hello() {
+	// gen:keep begin hello
+	panic("TODO")
+	// gen:keep end
+}
+
+func world() {
+	// gen:keep begin world
+	panic("TODO")
+	// gen:keep end
+}
+`
+	existing := `package main
+
+func hello() {
+	// gen:keep begin hello
+	println("hello")
+	println("custom")
+	// gen:keep end
+}
+
+func removed() {
+	// gen:keep begin removed
+	println("removed")
+	// gen:keep end
+}
+`
+	want := `package main
+
+func hello() {
+	// gen:keep begin hello
+	println("hello")
+	println("custom")
+	// gen:keep end
+}
+
+func world() {
+	// gen:keep begin world
+	panic("TODO")
+	// gen:keep end
+}
+`
+
+	merged, orphaned, err := MergeKeptRegions(rendered, existing)
+	if err != nil {
+		t.Fatalf("MergeKeptRegions() error = %v", err)
+	}
+	if merged != want {
+		t.Errorf("MergeKeptRegions() =\n%s\nwant\n%s", merged, want)
+	}
+
+	wantOrphaned := []KeptRegion{{Name: "removed", Body: "\tprintln(\"removed\")\n", Line: 11}}
+	if !reflect.DeepEqual(orphaned, wantOrphaned) {
+		t.Errorf("MergeKeptRegions() orphaned = %+v, want %+v", orphaned, wantOrphaned)
+	}
+}
+
+func TestMergeKeptRegions_OtherCommentStyles(t *testing.T) {
+	rendered := "<!-- gen:keep begin header -->\ndefault\n<!-- gen:keep end -->\n# gen:keep begin cfg\n# gen:keep end"
+	existing := "<!-- gen:keep begin header -->\n<h1>Custom</h1>\n<!-- gen:keep end -->\n# gen:keep begin cfg\nkey: value\n# gen:keep end\n"
+	want := "<!-- gen:keep begin header -->\n<h1>Custom</h1>\n<!-- gen:keep end -->\n# gen:keep begin cfg\nkey: value\n# gen:keep end"
+
+	merged, orphaned, err := MergeKeptRegions(rendered, existing)
+	if err != nil {
+		t.Fatalf("MergeKeptRegions() error = %v", err)
+	}
+	if merged != want {
+		t.Errorf("MergeKeptRegions() = %q, want %q", merged, want)
+	}
+	if len(orphaned) != 0 {
+		t.Errorf("MergeKeptRegions() orphaned = %+v, want none", orphaned)
+	}
+}
+
+func TestMergeKeptRegions_NoExistingRegions(t *testing.T) {
+	merged, orphaned, err := MergeKeptRegions("new", "old")
+	if err != nil || merged != "new" || orphaned != nil {
+		t.Errorf("MergeKeptRegions() = %q, %v, %v, want \"new\", nil, nil", merged, orphaned, err)
+	}
+}
+
+func TestParseKeptRegions_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"unclosed", "// gen:keep begin a\nbody\n"},
+		{"nested", "// gen:keep begin a\n// gen:keep begin b\n// gen:keep end\n// gen:keep end\n"},
+		{"duplicate", "// gen:keep begin a\n// gen:keep end\n// gen:keep begin a\n// gen:keep end\n"},
+		{"end without begin", "// gen:keep end\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseKeptRegions(tt.content); err == nil {
+				t.Errorf("parseKeptRegions() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestFileSystemWriter_KeptRegions(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "writer_keep_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	outputPath := filepath.Join(tempDir, "main.go")
+	existing := "// gen:keep begin a\ncustom\n// gen:keep end\n// gen:keep begin b\nold\n// gen:keep end\n"
+	if err := os.WriteFile(outputPath, []byte(existing), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	file := GeneratedFile{OutputPath: outputPath, Content: "v2\n// gen:keep begin a\nTODO\n// gen:keep end\n"}
+
+	// 默认情况下孤立区域导致错误，文件保持不变
+	writer := NewFileSystemWriter(WritePolicyOverwrite)
+	_, err = writer.WriteFile(file)
+	var orphanErr *OrphanedRegionsError
+	if !errors.As(err, &orphanErr) || len(orphanErr.Regions) != 1 || orphanErr.Regions[0].Name != "b" {
+		t.Fatalf("WriteFile() error = %v, want OrphanedRegionsError for region b", err)
+	}
+	if data, _ := os.ReadFile(outputPath); string(data) != existing {
+		t.Errorf("file modified despite orphaned regions: %q", string(data))
+	}
+
+	// 允许孤立区域时写入并报告
+	writer.AllowOrphanedRegions = true
+	result, err := writer.WriteFile(file)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if len(result.OrphanedRegions) != 1 || result.OrphanedRegions[0].Name != "b" {
+		t.Errorf("WriteFile() orphaned = %+v, want region b", result.OrphanedRegions)
+	}
+	want := "v2\n// gen:keep begin a\ncustom\n// gen:keep end\n"
+	if data, _ := os.ReadFile(outputPath); string(data) != want {
+		t.Errorf("output = %q, want %q", string(data), want)
+	}
+}
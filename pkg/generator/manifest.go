@@ -0,0 +1,345 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/clh021/generator/pkg/sandbox"
+	"github.com/pkg/errors"
+)
+
+// ManifestFileName 输出目录中记录生成结果的清单文件名
+const ManifestFileName = ".gen_manifest.json"
+
+// RenderCacheDirName 输出目录中保存上次生成内容的目录名，文件以内容哈希命名
+const RenderCacheDirName = ".gen_renders"
+
+// manifestVersion 清单文件格式版本
+const manifestVersion = 1
+
+// Manifest 记录一次生成写入的所有文件
+type Manifest struct {
+	Version int             `json:"version"`
+	Files   []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry 记录一个生成的文件
+type ManifestEntry struct {
+	// 相对于输出目录的路径（使用 / 分隔）
+	Path string `json:"path"`
+	// 相对于模板目录的模板路径（使用 / 分隔）
+	Template string `json:"template"`
+	// 写入后文件内容的哈希
+	Hash string `json:"hash"`
+	// 模板渲染结果的哈希，内容保存在 .gen_renders 中，作为三方合并的共同祖先
+	Render string `json:"render,omitempty"`
+}
+
+// ContentHash 计算内容的哈希，格式为 sha256:<hex>
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// LoadManifest 读取输出目录中的清单文件，文件不存在时返回 nil
+func LoadManifest(outputDir string) (*Manifest, error) {
+	path := filepath.Join(outputDir, ManifestFileName)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取清单文件失败: %s", path)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "解析清单文件失败: %s", path)
+	}
+	return &manifest, nil
+}
+
+// Save 将清单写入输出目录，条目按路径排序以保证结果稳定
+// 同时删除 .gen_renders 中不再被任何条目引用的渲染结果
+func (m *Manifest) Save(outputDir string) error {
+	m.Version = manifestVersion
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "序列化清单失败")
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return errors.Wrapf(err, "创建输出目录失败: %s", outputDir)
+	}
+	path := filepath.Join(outputDir, ManifestFileName)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return errors.Wrapf(err, "写入清单文件失败: %s", path)
+	}
+	return m.cleanRenderCache(outputDir)
+}
+
+// cleanRenderCache 删除不再被清单引用的渲染结果
+func (m *Manifest) cleanRenderCache(outputDir string) error {
+	dir := filepath.Join(outputDir, RenderCacheDirName)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "读取渲染缓存目录失败: %s", dir)
+	}
+
+	referenced := make(map[string]bool)
+	for _, entry := range m.Files {
+		if entry.Render != "" {
+			referenced[filepath.Base(renderCachePath(outputDir, entry.Render))] = true
+		}
+	}
+	for _, entry := range entries {
+		if referenced[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return errors.Wrapf(err, "删除渲染缓存失败: %s", entry.Name())
+		}
+	}
+	if len(referenced) == 0 {
+		os.Remove(dir)
+	}
+	return nil
+}
+
+// renderCachePath 返回指定哈希的渲染结果在缓存目录中的路径
+func renderCachePath(outputDir, hash string) string {
+	return filepath.Join(outputDir, RenderCacheDirName, strings.TrimPrefix(hash, "sha256:"))
+}
+
+// saveRender 将渲染结果保存到缓存目录，返回其哈希
+func saveRender(outputDir, content string) (string, error) {
+	hash := ContentHash(content)
+	path := renderCachePath(outputDir, hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", errors.Wrapf(err, "创建渲染缓存目录失败: %s", filepath.Dir(path))
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", errors.Wrapf(err, "写入渲染缓存失败: %s", path)
+	}
+	return hash, nil
+}
+
+// LoadRender 读取清单条目记录的上次渲染结果，没有记录或缓存已丢失时返回 false
+func (m *Manifest) LoadRender(outputDir, path string) (string, bool, error) {
+	entry, ok := m.Entry(path)
+	if !ok || entry.Render == "" {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(renderCachePath(outputDir, entry.Render))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.Wrapf(err, "读取渲染缓存失败: %s", entry.Render)
+	}
+	return string(data), true, nil
+}
+
+// Entry 返回指定相对路径的条目
+func (m *Manifest) Entry(path string) (ManifestEntry, bool) {
+	if m != nil {
+		for _, entry := range m.Files {
+			if entry.Path == path {
+				return entry, true
+			}
+		}
+	}
+	return ManifestEntry{}, false
+}
+
+// manifestPath 将路径转换为相对于 baseDir 的 / 分隔路径，无法转换时返回原路径
+func manifestPath(baseDir, path string) string {
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// resolveManifestPath 将清单中的路径转换为文件系统路径
+// 清单可能被手动修改，绝对路径以及（解析符号链接后）不在输出目录中的路径返回错误，以免删除输出目录之外的文件
+func resolveManifestPath(outputDir, path string) (string, error) {
+	fsPath := filepath.FromSlash(path)
+	if filepath.IsAbs(fsPath) || filepath.VolumeName(fsPath) != "" {
+		return "", errors.Errorf("清单中的路径 %s 是绝对路径", path)
+	}
+	fsPath = filepath.Join(outputDir, fsPath)
+
+	resolvedDir, err := sandbox.Resolve(outputDir)
+	if err != nil {
+		return "", errors.Wrapf(err, "解析输出目录失败: %s", outputDir)
+	}
+	resolved, err := sandbox.Resolve(fsPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "解析清单中的路径失败: %s", path)
+	}
+	if resolved == resolvedDir || !sandbox.Within(resolvedDir, resolved) {
+		return "", errors.Errorf("清单中的路径 %s 不在输出目录 %s 中", path, outputDir)
+	}
+	return fsPath, nil
+}
+
+// buildManifest 根据写入结果构建清单，并将使用 merge 策略的文件的渲染结果保存到缓存目录
+// 只记录生成器写入或内容与生成结果一致的文件；previous 中不属于本次写入的条目会被保留，以便之后清理
+// 其他策略的文件不缓存渲染结果，以免在输出目录中复制一份生成的内容（其中可能包含机密变量的值）
+func buildManifest(templateDir, outputDir string, files []GeneratedFile, results []WriteResult, previous *Manifest, defaultPolicy WritePolicy) (*Manifest, error) {
+	manifest := &Manifest{}
+	current := make(map[string]bool)
+	for i, result := range results {
+		path := manifestPath(outputDir, result.OutputPath)
+		switch result.Action {
+		case WriteActionCreated, WriteActionOverwritten, WriteActionUnchanged:
+		case WriteActionDropped, WriteActionRemoved:
+			// 文件已不存在，不再记录
+			current[path] = true
+			continue
+		default:
+			// 生成器没有写入的文件（如 skip-if-exists 跳过的现有文件）不记录，
+			// 否则用户的文件会被当作生成的文件而被 -prune 删除；已有的上次条目保持不变
+			continue
+		}
+
+		var render string
+		if effectivePolicy(files[i], defaultPolicy) == WritePolicyMerge {
+			var err error
+			if render, err = saveRender(outputDir, files[i].Content); err != nil {
+				return nil, err
+			}
+		}
+		current[path] = true
+		manifest.Files = append(manifest.Files, ManifestEntry{
+			Path:     path,
+			Template: manifestPath(templateDir, files[i].TemplatePath),
+			Hash:     result.Hash,
+			Render:   render,
+		})
+	}
+
+	if previous != nil {
+		for _, entry := range previous.Files {
+			if !current[entry.Path] {
+				manifest.Files = append(manifest.Files, entry)
+			}
+		}
+	}
+	return manifest, nil
+}
+
+// PruneAction 表示清理过期文件时对文件执行的操作
+type PruneAction string
+
+const (
+	// PruneActionDeleted 文件已删除（dry-run 时表示将被删除）
+	PruneActionDeleted PruneAction = "deleted"
+	// PruneActionModified 文件在生成后被修改过，未删除
+	PruneActionModified PruneAction = "modified"
+	// PruneActionMissing 文件已不存在
+	PruneActionMissing PruneAction = "missing"
+	// PruneActionRejected 清单中的路径不在输出目录中（清单被手动修改过），未删除
+	PruneActionRejected PruneAction = "rejected"
+)
+
+// PruneResult 表示一个过期文件的清理结果
+type PruneResult struct {
+	// 文件路径
+	Path string
+	// 执行的操作
+	Action PruneAction
+}
+
+// PruneStaleFiles 删除上次清单中记录、但不在本次生成结果中（或因内容只包含空白而未写入）的文件
+// 文件内容与清单中的哈希不一致（被用户修改过）时不删除，除非 force 为 true
+// dryRun 为 true 时只返回结果而不删除文件或修改清单
+func PruneStaleFiles(outputDir string, files []GeneratedFile, force, dryRun bool) ([]PruneResult, error) {
+	manifest, err := LoadManifest(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, nil
+	}
+
+	// 因内容只包含空白而未写入的文件同样视为过期
+	current := make(map[string]bool)
+	for _, file := range files {
+		if !file.Dropped() {
+			current[manifestPath(outputDir, file.OutputPath)] = true
+		}
+	}
+
+	var results []PruneResult
+	var kept []ManifestEntry
+	for _, entry := range manifest.Files {
+		if current[entry.Path] {
+			kept = append(kept, entry)
+			continue
+		}
+
+		path, err := resolveManifestPath(outputDir, entry.Path)
+		if err != nil {
+			results = append(results, PruneResult{Path: entry.Path, Action: PruneActionRejected})
+			kept = append(kept, entry)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			results = append(results, PruneResult{Path: path, Action: PruneActionMissing})
+			continue
+		}
+		if err != nil {
+			return results, errors.Wrapf(err, "读取过期文件失败: %s", path)
+		}
+
+		if ContentHash(string(data)) != entry.Hash && !force {
+			results = append(results, PruneResult{Path: path, Action: PruneActionModified})
+			kept = append(kept, entry)
+			continue
+		}
+
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				return results, errors.Wrapf(err, "删除过期文件失败: %s", path)
+			}
+			removeEmptyParents(filepath.Dir(path), outputDir)
+		}
+		results = append(results, PruneResult{Path: path, Action: PruneActionDeleted})
+	}
+
+	if !dryRun && len(results) > 0 {
+		manifest.Files = kept
+		if err := manifest.Save(outputDir); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// removeEmptyParents 从 dir 开始向上删除空目录，直到 stopDir（不含）
+func removeEmptyParents(dir, stopDir string) {
+	stopDir = filepath.Clean(stopDir)
+	for dir = filepath.Clean(dir); dir != stopDir && strings.HasPrefix(dir, stopDir+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,503 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clh021/generator/pkg/config"
+)
+
+func TestGenerator_WriteFilesManifest(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "manifest_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateDir := filepath.Join(tempDir, "templates")
+	variablesDir := filepath.Join(tempDir, "variables")
+	outputDir := filepath.Join(tempDir, "output")
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/a.txt.tpl":         "A {{ .name }}",
+		"templates/sub/b.txt.tpl":     "B",
+		"variables/variables.yaml":    "name: test",
+		"output/previous-stale.txt":   "stale",
+		"output/.gen_manifest.json":   `{"version":1,"files":[{"path":"previous-stale.txt","template":"old.txt.tpl","hash":"` + ContentHash("stale") + `"}]}`,
+		"output/untracked-by-gen.txt": "mine",
+	})
+
+	cfg := &config.Config{TemplateDir: templateDir, VariablesDir: variablesDir, OutputDir: outputDir}
+	gen := NewGenerator()
+	files, err := gen.GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	if _, err := gen.WriteFiles(files); err != nil {
+		t.Fatalf("WriteFiles() error = %v", err)
+	}
+
+	manifest, err := LoadManifest(outputDir)
+	if err != nil || manifest == nil {
+		t.Fatalf("LoadManifest() = %v, %v", manifest, err)
+	}
+
+	// 本次生成的文件和上次清单中的过期文件都应记录在清单中，按路径排序
+	want := []ManifestEntry{
+		{Path: "a.txt", Template: "a.txt.tpl", Hash: ContentHash("A test")},
+		{Path: "previous-stale.txt", Template: "old.txt.tpl", Hash: ContentHash("stale")},
+		{Path: "sub/b.txt", Template: "sub/b.txt.tpl", Hash: ContentHash("B")},
+	}
+	if len(manifest.Files) != len(want) {
+		t.Fatalf("manifest files = %+v, want %+v", manifest.Files, want)
+	}
+	for i := range want {
+		if manifest.Files[i] != want[i] {
+			t.Errorf("manifest.Files[%d] = %+v, want %+v", i, manifest.Files[i], want[i])
+		}
+	}
+
+	// 不使用 merge 策略时不缓存渲染结果
+	renderDir := filepath.Join(outputDir, RenderCacheDirName)
+	if fileExists(renderDir) {
+		t.Errorf("Expected no %s without the merge policy", RenderCacheDirName)
+	}
+
+	// 只缓存使用 merge 策略的文件的渲染结果
+	cfg.WritePolicies = []config.WritePolicyRule{{Pattern: "sub/**", Policy: string(WritePolicyMerge)}}
+	gen = NewGenerator()
+	if files, err = gen.GenerateFiles(cfg); err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	if _, err := gen.WriteFiles(files); err != nil {
+		t.Fatalf("WriteFiles() error = %v", err)
+	}
+	if manifest, err = LoadManifest(outputDir); err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	render, ok, err := manifest.LoadRender(outputDir, "sub/b.txt")
+	if err != nil || !ok || render != "B" {
+		t.Errorf("LoadRender(sub/b.txt) = %q, %v, %v, want %q", render, ok, err, "B")
+	}
+	for _, path := range []string{"a.txt", "previous-stale.txt"} {
+		if _, ok, _ := manifest.LoadRender(outputDir, path); ok {
+			t.Errorf("LoadRender(%s) expected no render", path)
+		}
+	}
+
+	// 文件不再使用 merge 策略后删除其渲染结果
+	cfg.WritePolicies = nil
+	gen = NewGenerator()
+	if files, err = gen.GenerateFiles(cfg); err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	if _, err := gen.WriteFiles(files); err != nil {
+		t.Fatalf("WriteFiles() error = %v", err)
+	}
+	if fileExists(renderDir) {
+		t.Errorf("Expected %s to be removed after leaving the merge policy", RenderCacheDirName)
+	}
+
+	// 禁用清单时不更新清单文件
+	cfg.DisableManifest = true
+	gen = NewGenerator()
+	files, err = gen.GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	if err := os.Remove(filepath.Join(outputDir, ManifestFileName)); err != nil {
+		t.Fatalf("Failed to remove manifest: %v", err)
+	}
+	if _, err := gen.WriteFiles(files); err != nil {
+		t.Fatalf("WriteFiles() error = %v", err)
+	}
+	if fileExists(filepath.Join(outputDir, ManifestFileName)) {
+		t.Error("Expected manifest not to be written when disabled")
+	}
+}
+
+func TestGenerator_WriteFilesManifestSkipIfExists(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "manifest_skip_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/user.txt.tpl":   "generated",
+		"variables/variables.yaml": "name: test",
+		"output/user.txt":          "written by hand",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+		WritePolicy:  string(WritePolicySkipIfExists),
+	}
+
+	gen := NewGenerator()
+	files, err := gen.GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	results, err := gen.WriteFiles(files)
+	if err != nil || len(results) != 1 || results[0].Action != WriteActionSkipped {
+		t.Fatalf("WriteFiles() = %+v, %v, want one skipped result", results, err)
+	}
+
+	// 跳过的现有文件不是生成的文件，不记录在清单中
+	manifest, err := LoadManifest(cfg.OutputDir)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if _, ok := manifest.Entry("user.txt"); ok {
+		t.Error("manifest records the skipped file user.txt")
+	}
+
+	// 模板删除后清理过期文件时不能删除用户的文件
+	if err := os.Remove(filepath.Join(cfg.TemplateDir, "user.txt.tpl")); err != nil {
+		t.Fatalf("Failed to remove template: %v", err)
+	}
+	gen = NewGenerator()
+	if files, err = gen.GenerateFiles(cfg); err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	if _, err := gen.Prune(files, false, false); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(cfg.OutputDir, "user.txt")); err != nil || string(data) != "written by hand" {
+		t.Errorf("user.txt = %q, %v, want the user's content", data, err)
+	}
+}
+
+func TestPruneStaleFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		force       bool
+		dryRun      bool
+		wantActions map[string]PruneAction
+		wantExists  map[string]bool
+		wantEntries []string
+	}{
+		{
+			name:  "prune unmodified",
+			force: false,
+			wantActions: map[string]PruneAction{
+				"stale/old.txt": PruneActionDeleted,
+				"modified.txt":  PruneActionModified,
+				"missing.txt":   PruneActionMissing,
+			},
+			wantExists:  map[string]bool{"current.txt": true, "stale/old.txt": false, "stale": false, "modified.txt": true},
+			wantEntries: []string{"current.txt", "modified.txt"},
+		},
+		{
+			name:  "force",
+			force: true,
+			wantActions: map[string]PruneAction{
+				"stale/old.txt": PruneActionDeleted,
+				"modified.txt":  PruneActionDeleted,
+				"missing.txt":   PruneActionMissing,
+			},
+			wantExists:  map[string]bool{"current.txt": true, "stale/old.txt": false, "modified.txt": false},
+			wantEntries: []string{"current.txt"},
+		},
+		{
+			name:   "dry run",
+			dryRun: true,
+			wantActions: map[string]PruneAction{
+				"stale/old.txt": PruneActionDeleted,
+				"modified.txt":  PruneActionModified,
+				"missing.txt":   PruneActionMissing,
+			},
+			wantExists:  map[string]bool{"current.txt": true, "stale/old.txt": true, "modified.txt": true},
+			wantEntries: []string{"current.txt", "missing.txt", "modified.txt", "stale/old.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir, err := os.MkdirTemp("", "prune_test")
+			if err != nil {
+				t.Fatalf("Failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(outputDir)
+
+			writeTestFiles(t, outputDir, map[string]string{
+				"current.txt":   "current",
+				"stale/old.txt": "old",
+				"modified.txt":  "edited by user",
+			})
+			manifest := &Manifest{Files: []ManifestEntry{
+				{Path: "current.txt", Template: "current.txt.tpl", Hash: ContentHash("current")},
+				{Path: "stale/old.txt", Template: "stale/old.txt.tpl", Hash: ContentHash("old")},
+				{Path: "modified.txt", Template: "modified.txt.tpl", Hash: ContentHash("generated")},
+				{Path: "missing.txt", Template: "missing.txt.tpl", Hash: ContentHash("missing")},
+			}}
+			if err := manifest.Save(outputDir); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			files := []GeneratedFile{{OutputPath: filepath.Join(outputDir, "current.txt"), Content: "current"}}
+			results, err := PruneStaleFiles(outputDir, files, tt.force, tt.dryRun)
+			if err != nil {
+				t.Fatalf("PruneStaleFiles() error = %v", err)
+			}
+
+			if len(results) != len(tt.wantActions) {
+				t.Errorf("PruneStaleFiles() returned %d results, want %d", len(results), len(tt.wantActions))
+			}
+			for _, result := range results {
+				rel, _ := filepath.Rel(outputDir, result.Path)
+				if want := tt.wantActions[filepath.ToSlash(rel)]; result.Action != want {
+					t.Errorf("PruneStaleFiles() %s action = %v, want %v", rel, result.Action, want)
+				}
+			}
+
+			for path, want := range tt.wantExists {
+				_, err := os.Stat(filepath.Join(outputDir, path))
+				if got := err == nil; got != want {
+					t.Errorf("%s exists = %v, want %v", path, got, want)
+				}
+			}
+
+			manifest, err = LoadManifest(outputDir)
+			if err != nil {
+				t.Fatalf("LoadManifest() error = %v", err)
+			}
+			var entries []string
+			for _, entry := range manifest.Files {
+				entries = append(entries, entry.Path)
+			}
+			if len(entries) != len(tt.wantEntries) {
+				t.Fatalf("manifest entries = %v, want %v", entries, tt.wantEntries)
+			}
+			for i := range entries {
+				if entries[i] != tt.wantEntries[i] {
+					t.Errorf("manifest entries = %v, want %v", entries, tt.wantEntries)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestPruneStaleFiles_OutsideOutputDir(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "prune_outside_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	content := "not generated"
+	writeTestFiles(t, tempDir, map[string]string{"outside.txt": content, "output/inside.txt": "inside"})
+	outputDir := filepath.Join(tempDir, "output")
+	outside := filepath.Join(tempDir, "outside.txt")
+	if err := os.Symlink(tempDir, filepath.Join(outputDir, "link")); err != nil {
+		t.Skipf("Symlink() error = %v", err)
+	}
+
+	// 手动修改的清单中指向输出目录之外的路径，即使 force 也不能删除
+	manifest := &Manifest{Files: []ManifestEntry{
+		{Path: "../outside.txt", Hash: ContentHash(content)},
+		{Path: filepath.ToSlash(outside), Hash: ContentHash(content)},
+		{Path: "link/outside.txt", Hash: ContentHash(content)},
+		{Path: "sub/../../outside.txt", Hash: ContentHash(content)},
+		{Path: ".", Hash: ContentHash(content)},
+		{Path: "inside.txt", Hash: ContentHash("inside")},
+	}}
+	if err := manifest.Save(outputDir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	results, err := PruneStaleFiles(outputDir, nil, true, false)
+	if err != nil {
+		t.Fatalf("PruneStaleFiles() error = %v", err)
+	}
+	want := map[string]PruneAction{
+		"../outside.txt":                       PruneActionRejected,
+		filepath.ToSlash(outside):              PruneActionRejected,
+		"link/outside.txt":                     PruneActionRejected,
+		"sub/../../outside.txt":                PruneActionRejected,
+		".":                                    PruneActionRejected,
+		filepath.Join(outputDir, "inside.txt"): PruneActionDeleted,
+	}
+	if len(results) != len(want) {
+		t.Errorf("PruneStaleFiles() = %+v, want %d results", results, len(want))
+	}
+	for _, result := range results {
+		if result.Action != want[result.Path] {
+			t.Errorf("PruneStaleFiles() %s action = %v, want %v", result.Path, result.Action, want[result.Path])
+		}
+	}
+
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the output directory was removed: %v", err)
+	}
+	manifest, err = LoadManifest(outputDir)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if len(manifest.Files) != 5 {
+		t.Errorf("manifest entries = %+v, want the 5 rejected entries kept", manifest.Files)
+	}
+}
+
+func TestPruneStaleFiles_NoManifest(t *testing.T) {
+	outputDir, err := os.MkdirTemp("", "prune_none_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	results, err := PruneStaleFiles(outputDir, nil, false, false)
+	if err != nil || results != nil {
+		t.Errorf("PruneStaleFiles() = %v, %v, want nil, nil", results, err)
+	}
+}
+
+func TestGenerator_WriteFilesSkipEmpty(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "skip_empty_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/docs/auth.md.tpl":   "{{ if .auth }}auth{{ end }}\n",
+		"templates/docs/edited.md.tpl": "{{ if .auth }}edited{{ end }}\n",
+		"templates/main.go.tpl":        "{{ if .auth }}main{{ end }}\n",
+		"variables/variables.yaml":     "auth: true",
+	})
+	cfg := &config.Config{
+		TemplateDir:        filepath.Join(tempDir, "templates"),
+		VariablesDir:       filepath.Join(tempDir, "variables"),
+		OutputDir:          filepath.Join(tempDir, "output"),
+		SkipEmptyTemplates: []string{"docs/**"},
+	}
+	generate := func() map[string]WriteAction {
+		t.Helper()
+		gen := NewGenerator()
+		files, err := gen.GenerateFiles(cfg)
+		if err != nil {
+			t.Fatalf("GenerateFiles() error = %v", err)
+		}
+		results, err := gen.WriteFiles(files)
+		if err != nil {
+			t.Fatalf("WriteFiles() error = %v", err)
+		}
+		actions := make(map[string]WriteAction)
+		for _, result := range results {
+			rel, _ := filepath.Rel(cfg.OutputDir, result.OutputPath)
+			actions[filepath.ToSlash(rel)] = result.Action
+		}
+		return actions
+	}
+
+	generate()
+	// 用户修改过的文件内容为空时不删除
+	writeTestFiles(t, tempDir, map[string]string{
+		"output/docs/edited.md":    "edited by user\n",
+		"variables/variables.yaml": "auth: false",
+	})
+
+	actions := generate()
+	want := map[string]WriteAction{
+		"docs/auth.md":   WriteActionRemoved,
+		"docs/edited.md": WriteActionKeptModified,
+		// 未匹配 skip_empty_templates 的模板照常写入
+		"main.go": WriteActionOverwritten,
+	}
+	for rel, action := range want {
+		if actions[rel] != action {
+			t.Errorf("WriteFiles() action for %s = %q, want %q", rel, actions[rel], action)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, "docs", "auth.md")); !os.IsNotExist(err) {
+		t.Errorf("docs/auth.md should be removed, stat error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(cfg.OutputDir, "docs", "edited.md")); err != nil || string(data) != "edited by user\n" {
+		t.Errorf("docs/edited.md = %q, %v, want the user's content", data, err)
+	}
+
+	// 删除的文件不再记录在清单中，保留的文件由 -prune 处理
+	manifest, err := LoadManifest(cfg.OutputDir)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if _, ok := manifest.Entry("docs/auth.md"); ok {
+		t.Error("manifest still records docs/auth.md")
+	}
+	if _, ok := manifest.Entry("docs/edited.md"); !ok {
+		t.Error("manifest no longer records docs/edited.md")
+	}
+
+	// 再次生成时空文件不写入
+	actions = generate()
+	if actions["docs/auth.md"] != WriteActionDropped {
+		t.Errorf("WriteFiles() action for docs/auth.md = %q, want %q", actions["docs/auth.md"], WriteActionDropped)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, "docs", "auth.md")); !os.IsNotExist(err) {
+		t.Errorf("docs/auth.md should not be written, stat error = %v", err)
+	}
+}
+
+func TestGenerator_WriteFilesSkipEmptySkipIfExists(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "skip_empty_existing_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/user.txt.tpl":   "{{ if .auth }}generated{{ end }}\n",
+		"variables/variables.yaml": "auth: true",
+		"output/user.txt":          "written by hand",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+		WritePolicy:  string(WritePolicySkipIfExists),
+		SkipEmpty:    true,
+	}
+	generate := func() WriteAction {
+		t.Helper()
+		gen := NewGenerator()
+		files, err := gen.GenerateFiles(cfg)
+		if err != nil {
+			t.Fatalf("GenerateFiles() error = %v", err)
+		}
+		results, err := gen.WriteFiles(files)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("WriteFiles() = %+v, %v, want one result", results, err)
+		}
+		return results[0].Action
+	}
+
+	if action := generate(); action != WriteActionSkipped {
+		t.Errorf("WriteFiles() action = %q, want %q", action, WriteActionSkipped)
+	}
+
+	// 模板内容变为空白时不能删除被跳过的用户文件
+	writeTestFiles(t, tempDir, map[string]string{"variables/variables.yaml": "auth: false"})
+	if action := generate(); action != WriteActionDropped {
+		t.Errorf("WriteFiles() action = %q, want %q", action, WriteActionDropped)
+	}
+	if data, err := os.ReadFile(filepath.Join(cfg.OutputDir, "user.txt")); err != nil || string(data) != "written by hand" {
+		t.Errorf("user.txt = %q, %v, want the user's content", data, err)
+	}
+}
+
+// writeTestFiles 在 baseDir 下创建测试文件，键为相对路径
+func writeTestFiles(t *testing.T, baseDir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(baseDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+}
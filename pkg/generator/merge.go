@@ -0,0 +1,177 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// 冲突标记
+const (
+	conflictOursMarker   = "<<<<<<< ours (current file)"
+	conflictSepMarker    = "======="
+	conflictTheirsMarker = ">>>>>>> theirs (new template)"
+)
+
+// ErrUnresolvedConflict 在现有文件中仍有未解决的合并冲突时返回
+var ErrUnresolvedConflict = errors.New("文件中存在未解决的合并冲突")
+
+// MergeThreeWay 以 base 为共同祖先，对 ours（磁盘上的文件）与 theirs（新生成的内容）做三方行合并
+// 只有一方修改的部分自动采用修改方的内容，双方都修改且不同的部分写入冲突标记
+// 返回合并后的内容以及冲突数量
+func MergeThreeWay(base, ours, theirs string) (string, int) {
+	o := splitLines(base)
+	a := splitLines(ours)
+	b := splitLines(theirs)
+	mapA := matchLines(o, a)
+	mapB := matchLines(o, b)
+
+	var sb strings.Builder
+	conflicts := 0
+	oi, ai, bi := 0, 0, 0
+	for oi < len(o) || ai < len(a) || bi < len(b) {
+		// 三方一致的稳定块
+		n := 0
+		for oi+n < len(o) && mapA[oi+n] == ai+n && mapB[oi+n] == bi+n {
+			n++
+		}
+		if n > 0 {
+			writeLines(&sb, o[oi:oi+n])
+			oi, ai, bi = oi+n, ai+n, bi+n
+			continue
+		}
+
+		// 找到下一行在双方中都保留的共同行，之前的部分为不稳定块
+		j := oi
+		for j < len(o) && (mapA[j] < 0 || mapB[j] < 0) {
+			j++
+		}
+		aEnd, bEnd := len(a), len(b)
+		if j < len(o) {
+			aEnd, bEnd = mapA[j], mapB[j]
+		}
+
+		baseChunk, oursChunk, theirsChunk := o[oi:j], a[ai:aEnd], b[bi:bEnd]
+		switch {
+		case equalLines(oursChunk, baseChunk), equalLines(oursChunk, theirsChunk):
+			writeLines(&sb, theirsChunk)
+		case equalLines(theirsChunk, baseChunk):
+			writeLines(&sb, oursChunk)
+		default:
+			conflicts++
+			writeMarker(&sb, conflictOursMarker)
+			writeLines(&sb, oursChunk)
+			writeMarker(&sb, conflictSepMarker)
+			writeLines(&sb, theirsChunk)
+			writeMarker(&sb, conflictTheirsMarker)
+		}
+		oi, ai, bi = j, aEnd, bEnd
+	}
+	return sb.String(), conflicts
+}
+
+// MergeTwoWay 在没有共同祖先时合并 ours 与 theirs
+// 以双方的公共行作为祖先，因此所有不同之处都会成为冲突
+func MergeTwoWay(ours, theirs string) (string, int) {
+	a := splitLines(ours)
+	b := splitLines(theirs)
+	var sb strings.Builder
+	for _, op := range diffLines(a, b) {
+		if op.kind == diffEqual {
+			sb.WriteString(a[op.aIndex])
+		}
+	}
+	return MergeThreeWay(sb.String(), ours, theirs)
+}
+
+// HasConflictMarkers 判断内容中是否含有合并冲突标记
+func HasConflictMarkers(content string) bool {
+	var ours, theirs bool
+	for _, line := range splitLines(content) {
+		switch {
+		case strings.HasPrefix(line, "<<<<<<< "):
+			ours = true
+		case strings.HasPrefix(line, ">>>>>>> "):
+			theirs = true
+		}
+	}
+	return ours && theirs
+}
+
+// matchLines 返回 base 中每一行在 other 中对应的下标，被删除的行为 -1
+func matchLines(base, other []string) []int {
+	m := make([]int, len(base))
+	for i := range m {
+		m[i] = -1
+	}
+	for _, op := range diffLines(base, other) {
+		if op.kind == diffEqual {
+			m[op.aIndex] = op.bIndex
+		}
+	}
+	return m
+}
+
+// equalLines 判断两组行是否相同
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeLines 写入多行内容
+func writeLines(sb *strings.Builder, lines []string) {
+	for _, line := range lines {
+		sb.WriteString(line)
+	}
+}
+
+// writeMarker 写入一行冲突标记，前一行缺少换行符时先补上
+func writeMarker(sb *strings.Builder, marker string) {
+	if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(marker)
+	sb.WriteString("\n")
+}
+
+// mergeWithBase 按三方合并策略合并现有文件，没有上次生成的内容时退化为两方合并
+func (f GeneratedFile) mergeWithBase(existing string) (GeneratedFile, int, error) {
+	if HasConflictMarkers(existing) {
+		return f, 0, errors.Wrapf(ErrUnresolvedConflict, "%s", f.OutputPath)
+	}
+
+	var conflicts int
+	if f.Base != nil {
+		f.Content, conflicts = MergeThreeWay(*f.Base, existing, f.Content)
+	} else {
+		f.Content, conflicts = MergeTwoWay(existing, f.Content)
+	}
+	return f, conflicts, nil
+}
+
+// MergeExisting 按写入策略将现有文件的内容合并到生成的内容中
+// merge 策略下先与现有文件做三方合并，然后注入现有文件中的保留区域
+// 返回合并后的文件、在新内容中已不存在的保留区域以及冲突数量
+func (f GeneratedFile) MergeExisting(existing string, policy WritePolicy) (GeneratedFile, []KeptRegion, int, error) {
+	var conflicts int
+	if policy == WritePolicyMerge {
+		var err error
+		f, conflicts, err = f.mergeWithBase(existing)
+		if err != nil {
+			return f, nil, 0, err
+		}
+	}
+
+	f, orphaned, err := f.MergeKeptRegions(existing)
+	if err != nil {
+		return f, nil, 0, err
+	}
+	return f, orphaned, conflicts, nil
+}
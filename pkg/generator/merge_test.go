@@ -0,0 +1,222 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clh021/generator/pkg/config"
+	"github.com/pkg/errors"
+)
+
+func TestMergeThreeWay(t *testing.T) {
+	tests := []struct {
+		name          string
+		base          string
+		ours          string
+		theirs        string
+		want          string
+		wantConflicts int
+	}{
+		{
+			name:   "no changes",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nb\nc\n",
+			want:   "a\nb\nc\n",
+		},
+		{
+			name:   "only template changed",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nB\nc\nd\n",
+			want:   "a\nB\nc\nd\n",
+		},
+		{
+			name:   "only file changed",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nmine\nc\n",
+			theirs: "a\nb\nc\n",
+			want:   "a\nb\nmine\nc\n",
+		},
+		{
+			name:   "both changed different lines",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "a\nmine\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nnew\n",
+			want:   "a\nmine\nc\nd\nnew\n",
+		},
+		{
+			name:   "both made the same change",
+			base:   "a\nb\nc\n",
+			ours:   "a\nx\nc\n",
+			theirs: "a\nx\nc\n",
+			want:   "a\nx\nc\n",
+		},
+		{
+			name:          "conflict",
+			base:          "a\nb\nc\n",
+			ours:          "a\nmine\nc\n",
+			theirs:        "a\nnew\nc\n",
+			want:          "a\n<<<<<<< ours (current file)\nmine\n=======\nnew\n>>>>>>> theirs (new template)\nc\n",
+			wantConflicts: 1,
+		},
+		{
+			name:          "conflict without trailing newline",
+			base:          "a\nb",
+			ours:          "a\nmine",
+			theirs:        "a\nnew",
+			want:          "a\n<<<<<<< ours (current file)\nmine\n=======\nnew\n>>>>>>> theirs (new template)\n",
+			wantConflicts: 1,
+		},
+		{
+			name:   "line deleted by template",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\nmine\n",
+			theirs: "a\nc\n",
+			want:   "a\nc\nmine\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := MergeThreeWay(tt.base, tt.ours, tt.theirs)
+			if got != tt.want {
+				t.Errorf("MergeThreeWay() = %q, want %q", got, tt.want)
+			}
+			if conflicts != tt.wantConflicts {
+				t.Errorf("MergeThreeWay() conflicts = %d, want %d", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestMergeTwoWay(t *testing.T) {
+	got, conflicts := MergeTwoWay("a\nmine\nc\n", "a\nnew\nc\n")
+	want := "a\n<<<<<<< ours (current file)\nmine\n=======\nnew\n>>>>>>> theirs (new template)\nc\n"
+	if got != want || conflicts != 1 {
+		t.Errorf("MergeTwoWay() = %q, %d, want %q, 1", got, conflicts, want)
+	}
+
+	got, conflicts = MergeTwoWay("same\n", "same\n")
+	if got != "same\n" || conflicts != 0 {
+		t.Errorf("MergeTwoWay() = %q, %d, want %q, 0", got, conflicts, "same\n")
+	}
+}
+
+func TestHasConflictMarkers(t *testing.T) {
+	merged, _ := MergeThreeWay("b\n", "x\n", "y\n")
+	if !HasConflictMarkers(merged) {
+		t.Errorf("HasConflictMarkers(%q) = false, want true", merged)
+	}
+	if HasConflictMarkers("<<<<<<< only one side\n") {
+		t.Error("HasConflictMarkers() = true for incomplete markers, want false")
+	}
+}
+
+func TestFileSystemWriter_Merge(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "writer_merge_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	outputPath := filepath.Join(tempDir, "out.txt")
+	if err := os.WriteFile(outputPath, []byte("a\nmine\nc\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	writer := NewFileSystemWriter(WritePolicyMerge)
+	base := "a\nb\nc\n"
+	result, err := writer.WriteFile(GeneratedFile{OutputPath: outputPath, Content: "a\nb\nc\nd\n", Base: &base})
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if result.Action != WriteActionOverwritten || result.Conflicts != 0 {
+		t.Errorf("WriteFile() = %+v, want overwritten without conflicts", result)
+	}
+	data, _ := os.ReadFile(outputPath)
+	if string(data) != "a\nmine\nc\nd\n" {
+		t.Errorf("output content = %q, want %q", string(data), "a\nmine\nc\nd\n")
+	}
+
+	// 冲突时写入冲突标记，未解决前再次写入报错
+	base = "a\nmine\nc\nd\n"
+	result, err = writer.WriteFile(GeneratedFile{OutputPath: outputPath, Content: "a\nnew\nc\nd\n", Base: &base})
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if result.Conflicts != 0 {
+		t.Errorf("WriteFile() conflicts = %d, want 0", result.Conflicts)
+	}
+
+	if err := os.WriteFile(outputPath, []byte("a\nedited\nc\nd\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	base = "a\nnew\nc\nd\n"
+	result, err = writer.WriteFile(GeneratedFile{OutputPath: outputPath, Content: "a\nnewer\nc\nd\n", Base: &base})
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if result.Conflicts != 1 {
+		t.Errorf("WriteFile() conflicts = %d, want 1", result.Conflicts)
+	}
+
+	_, err = writer.WriteFile(GeneratedFile{OutputPath: outputPath, Content: "a\nnewer\nc\nd\n", Base: &base})
+	if errors.Cause(err) != ErrUnresolvedConflict {
+		t.Errorf("WriteFile() error = %v, want %v", err, ErrUnresolvedConflict)
+	}
+}
+
+func TestGenerator_MergeWithPreviousRender(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "generator_merge_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/main.go.tpl":    "package main\n\nfunc main() {\n}\n",
+		"variables/variables.yaml": "name: test",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+		WritePolicy:  string(WritePolicyMerge),
+	}
+
+	generate := func() {
+		t.Helper()
+		gen := NewGenerator()
+		files, err := gen.GenerateFiles(cfg)
+		if err != nil {
+			t.Fatalf("GenerateFiles() error = %v", err)
+		}
+		if _, err := gen.WriteFiles(files); err != nil {
+			t.Fatalf("WriteFiles() error = %v", err)
+		}
+	}
+
+	// 首次生成，然后手动修改输出文件
+	generate()
+	outputPath := filepath.Join(tempDir, "output", "main.go")
+	if err := os.WriteFile(outputPath, []byte("package main\n\nfunc main() {\n\trun()\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// 模板升级后重新生成，手动修改与模板修改都应保留
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/main.go.tpl": "// Code generated for {{ .name }}.\npackage main\n\nfunc main() {\n}\n",
+	})
+	generate()
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	want := "// Code generated for test.\npackage main\n\nfunc main() {\n\trun()\n}\n"
+	if string(data) != want {
+		t.Errorf("output content = %q, want %q", string(data), want)
+	}
+}
@@ -1,10 +1,19 @@
 package generator
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/clh021/generator/internal/template"
+	"github.com/clh021/generator/pkg/sandbox"
+	"github.com/pkg/errors"
 )
 
 // PathProcessor 定义路径处理器接口
@@ -14,7 +23,10 @@ type PathProcessor interface {
 }
 
 // DefaultPathProcessor 默认的路径处理器实现
-type DefaultPathProcessor struct{}
+type DefaultPathProcessor struct {
+	// Strict 为 true 时路径中的变量无法替换会返回 *PathVariableError，否则输出警告并保留原始字符串
+	Strict bool
+}
 
 // NewDefaultPathProcessor 创建默认的路径处理器
 func NewDefaultPathProcessor() *DefaultPathProcessor {
@@ -26,53 +38,231 @@ func (p *DefaultPathProcessor) ProcessOutputPath(templateFile TemplateFile, outp
 	// 移除模板扩展名
 	relPathWithoutExt := removeTemplateExtension(templateFile.RelativePath)
 
-	// 构建初始输出路径
-	outputPath := filepath.Join(outputDir, relPathWithoutExt)
-
-	// 处理路径中的变量
-	processedPath, err := p.processTemplatePath(outputPath, variables)
+	// 处理路径中的变量，输出目录本身不做替换
+	processedPath, err := p.processTemplatePath(relPathWithoutExt, variables)
 	if err != nil {
-		return outputPath, err
+		return filepath.Join(outputDir, relPathWithoutExt), err
 	}
 
-	return processedPath, nil
+	return filepath.Join(outputDir, processedPath), nil
 }
 
 // processTemplatePath 处理路径中的变量引用
-// 查找形如 __variable__ 的模板变量并尝试替换
-// 如果变量不存在，则输出警告并保留原始字符串
+// 查找形如 __variable__ 或 __variable|filter__ 的模板变量并尝试替换，变量的写法见 lookupPathVariable
+// 字符串、数字和布尔值可以替换，变量不存在或是其他类型的值时，
+// 严格模式下返回 *PathVariableError，否则输出警告并保留原始字符串；未知的过滤器总是返回错误
 func (p *DefaultPathProcessor) processTemplatePath(path string, variables map[string]interface{}) (string, error) {
-	re := regexp.MustCompile(`__([^_]+)__`)
-	matches := re.FindAllStringSubmatch(path, -1)
+	var unresolved []string
+	result := pathVariablePattern.ReplaceAllStringFunc(path, func(fullMatch string) string {
+		varName := pathVariablePattern.FindStringSubmatch(fullMatch)[1]
 
-	result := path
-	for _, match := range matches {
-		if len(match) < 2 {
-			continue
+		if err := checkPathFilters(varName); err != nil {
+			unresolved = append(unresolved, fmt.Sprintf("%s: %v", varName, err))
+			return fullMatch
+		}
+		value, err := pathVariableValue(variables, varName)
+		if err != nil {
+			if p.Strict {
+				unresolved = append(unresolved, fmt.Sprintf("%s: %v", varName, err))
+			} else {
+				log.Printf("警告: 在路径 %s 中无法替换变量 %s（%v），保留原始字符串", path, varName, err)
+			}
+			return fullMatch
 		}
+		return value
+	})
+	if len(unresolved) > 0 {
+		return path, &PathVariableError{Path: path, Unresolved: unresolved}
+	}
 
-		fullMatch := match[0] // __variable__
-		varName := match[1]   // variable
+	// 规范化路径
+	return filepath.Clean(result), nil
+}
 
-		// 查找变量值
-		value, ok := variables[varName]
+// pathVariablePattern 路径中的变量引用 __variable__，可以带过滤器 __variable|filter__
+// 变量名中可以包含单个下划线，但不能以下划线开头或结尾，也不能包含连续的下划线，这些下划线需要写为 %5F
+var pathVariablePattern = regexp.MustCompile(`__([^_/\\](?:[^_/\\]|_[^_/\\])*)__`)
+
+// PathVariableError 严格模式下路径中的变量无法替换时返回的错误
+type PathVariableError struct {
+	// 模板的相对路径（已移除模板扩展名）
+	Path string
+	// 无法替换的变量及原因
+	Unresolved []string
+}
+
+func (e *PathVariableError) Error() string {
+	return fmt.Sprintf("路径 %s 中的变量无法替换: %s", e.Path, strings.Join(e.Unresolved, "；"))
+}
+
+// pathVariableValue 返回路径变量替换后的字符串
+// 字符串原样使用，整数、浮点数和布尔值格式化为其字面量，如 8080、1.5、true
+// | 之后是依次应用的过滤器，如 __entity.name|plural|kebab__，与模板函数共用同一实现（template.StringFilters）
+func pathVariableValue(variables map[string]interface{}, placeholder string) (string, error) {
+	parts := strings.Split(placeholder, "|")
+	value, err := lookupPathVariable(variables, parts[0])
+	if err != nil {
+		return "", err
+	}
+
+	var result string
+	switch v := value.(type) {
+	case string:
+		result = v
+	case bool:
+		result = strconv.FormatBool(v)
+	case int:
+		result = strconv.Itoa(v)
+	case int64:
+		result = strconv.FormatInt(v, 10)
+	case uint64:
+		result = strconv.FormatUint(v, 10)
+	case float64:
+		result = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return "", errors.Errorf("值不是字符串、数字或布尔值（实际为 %T）", value)
+	}
+
+	for _, name := range parts[1:] {
+		filter, ok := template.StringFilters[name]
 		if !ok {
-			log.Printf("警告: 在路径 %s 中找不到变量 %s，保留原始字符串", path, varName)
-			continue
+			return "", errors.Errorf("未知的过滤器 %s", name)
 		}
+		result = filter(result)
+	}
+	return result, nil
+}
 
-		// 将变量值转换为字符串并替换
-		strValue, ok := value.(string)
-		if !ok {
-			log.Printf("警告: 在路径 %s 中变量 %s 的值不是字符串，保留原始字符串", path, varName)
-			continue
+// checkPathFilters 检查占位符中的过滤器是否存在
+func checkPathFilters(placeholder string) error {
+	for _, name := range strings.Split(placeholder, "|")[1:] {
+		if _, ok := template.StringFilters[name]; !ok {
+			return errors.Errorf("未知的过滤器 %s，可用的过滤器: %s", name, strings.Join(pathFilterNames(), ", "))
 		}
+	}
+	return nil
+}
 
-		result = strings.Replace(result, fullMatch, strValue, -1)
+// pathFilterNames 返回可用的过滤器名称，按字母排序
+func pathFilterNames() []string {
+	names := make([]string, 0, len(template.StringFilters))
+	for name := range template.StringFilters {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	// 规范化路径
-	return filepath.Clean(result), nil
+// lookupPathVariable 查找路径占位符中的变量
+// name 以 . 分隔映射的键，数字可以作为列表下标，如 __project.name__、__routes.0.name__；
+// 键中的字符可以用 %XX 转义，如 %5F 表示 _、%2E 表示键中的 .
+// 为兼容旧的写法，与 name 完全相同的顶层变量优先
+func lookupPathVariable(variables map[string]interface{}, name string) (interface{}, error) {
+	if value, ok := variables[name]; ok {
+		return value, nil
+	}
+
+	var current interface{} = variables
+	for _, segment := range strings.Split(name, ".") {
+		key, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, errors.Errorf("转义无效: %s", segment)
+		}
+		switch t := current.(type) {
+		case map[string]interface{}:
+			value, ok := t[key]
+			if !ok {
+				return nil, errors.New("变量不存在")
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(t) {
+				return nil, errors.New("变量不存在")
+			}
+			current = t[index]
+		default:
+			return nil, errors.New("变量不存在")
+		}
+	}
+	return current, nil
+}
+
+// OutputPathError 输出路径超出输出目录时返回的错误
+type OutputPathError struct {
+	// 模板相对于模板目录的路径
+	Template string
+	// 处理后的输出路径
+	OutputPath string
+	// 输出目录
+	OutputDir string
+	// 值包含 .. 的路径变量
+	Variables []string
+}
+
+func (e *OutputPathError) Error() string {
+	cause := "，可能是输出目录中的符号链接指向了外部"
+	if len(e.Variables) > 0 {
+		cause = fmt.Sprintf("，路径变量 %s 的值包含 ..", strings.Join(e.Variables, ", "))
+	}
+	return fmt.Sprintf("模板 %s 的输出路径 %s 超出输出目录 %s%s；确需写入输出目录之外时，请用 allow_outside_output 允许该模板",
+		e.Template, e.OutputPath, e.OutputDir, cause)
+}
+
+// checkOutputPath 检查输出路径在解析已存在的上级目录中的符号链接后是否仍位于输出目录中
+// 超出时返回 *OutputPathError，并指出值导致路径超出的路径变量
+func checkOutputPath(templateFile TemplateFile, outputPath, outputDir string, variables map[string]interface{}) error {
+	resolvedDir, err := sandbox.Resolve(outputDir)
+	if err != nil {
+		return err
+	}
+	resolved, err := sandbox.Resolve(outputPath)
+	if err != nil {
+		return err
+	}
+	if sandbox.Within(resolvedDir, resolved) {
+		return nil
+	}
+
+	pathErr := &OutputPathError{Template: templateFile.RelativePath, OutputPath: outputPath, OutputDir: outputDir}
+	for _, match := range pathVariablePattern.FindAllStringSubmatch(templateFile.RelativePath, -1) {
+		value, err := pathVariableValue(variables, match[1])
+		if err == nil && escapesDir(value) && !containsString(pathErr.Variables, match[1]) {
+			pathErr.Variables = append(pathErr.Variables, match[1])
+		}
+	}
+	return pathErr
+}
+
+// escapesDir 判断路径变量的值是否包含 ..，可能使路径离开所在目录
+// 绝对路径的值同样拼接在输出目录之下，不会离开输出目录
+func escapesDir(value string) bool {
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny 判断模板相对路径是否匹配任意一个模式，如 allow_outside_output、skip_empty_templates 中的模式
+func matchAny(patterns []string, relativePath string) bool {
+	slashPath := filepath.ToSlash(relativePath)
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, slashPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
 
 // removeTemplateExtension 移除模板文件的扩展名
@@ -1,8 +1,14 @@
 package generator
 
 import (
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/clh021/generator/pkg/config"
+	"github.com/pkg/errors"
 )
 
 func TestDefaultPathProcessor_ProcessOutputPath(t *testing.T) {
@@ -73,7 +79,7 @@ func TestDefaultPathProcessor_ProcessOutputPath(t *testing.T) {
 			variables: map[string]interface{}{
 				"number": 123,
 			},
-			want:    filepath.Clean("/output/__number__.txt"),
+			want:    filepath.Clean("/output/123.txt"),
 			wantErr: false,
 		},
 		{
@@ -165,3 +171,204 @@ func TestNewDefaultPathProcessor(t *testing.T) {
 		t.Error("NewDefaultPathProcessor() returned nil")
 	}
 }
+
+func TestGenerateFiles_OutputPathConfinement(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "output_path_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	// 临时目录本身可能位于符号链接之下（如 macOS 的 /tmp）
+	if tempDir, err = filepath.EvalSymlinks(tempDir); err != nil {
+		t.Fatalf("Failed to resolve temp dir: %v", err)
+	}
+
+	outputDir := filepath.Join(tempDir, "output")
+	if err := os.MkdirAll(filepath.Join(tempDir, "outside"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	// 输出目录中指向外部的符号链接
+	if err := os.Symlink(filepath.Join(tempDir, "outside"), filepath.Join(outputDir, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		template  string
+		vars      string
+		allow     []string
+		want      string
+		wantVars  []string
+		wantError bool
+	}{
+		{
+			name:     "path inside output dir",
+			template: "__dir__/a.txt.tpl",
+			vars:     "dir: sub/../pkg",
+			want:     filepath.Join(outputDir, "pkg", "a.txt"),
+		},
+		{
+			name:      "parent traversal",
+			template:  "__dir__/a.txt.tpl",
+			vars:      "dir: ../outside",
+			wantVars:  []string{"dir"},
+			wantError: true,
+		},
+		{
+			name:     "absolute path is joined to output dir",
+			template: "__dir__/__name__.tpl",
+			vars:     "dir: /outside\nname: a.txt",
+			want:     filepath.Join(outputDir, "outside", "a.txt"),
+		},
+		{
+			name:      "symlink out of output dir",
+			template:  "link/a.txt.tpl",
+			vars:      "name: x",
+			wantError: true,
+		},
+		{
+			name:     "allowed template",
+			template: "__dir__/a.txt.tpl",
+			vars:     "dir: ../outside",
+			allow:    []string{"__dir__/**"},
+			want:     filepath.Join(outputDir, "..", "outside", "a.txt"),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caseDir := filepath.Join(tempDir, strings.Repeat("c", i+1))
+			writeTestFiles(t, caseDir, map[string]string{
+				"templates/" + tt.template: "ok",
+				"variables/variables.yaml": tt.vars,
+			})
+			cfg := &config.Config{
+				TemplateDir:        filepath.Join(caseDir, "templates"),
+				VariablesDir:       filepath.Join(caseDir, "variables"),
+				OutputDir:          outputDir,
+				AllowOutsideOutput: tt.allow,
+			}
+
+			generated, err := NewGenerator().GenerateFiles(cfg)
+			if tt.wantError {
+				var pathErr *OutputPathError
+				if !errors.As(err, &pathErr) {
+					t.Fatalf("GenerateFiles() error = %v, want *OutputPathError", err)
+				}
+				if pathErr.Template != filepath.FromSlash(tt.template) || strings.Join(pathErr.Variables, ",") != strings.Join(tt.wantVars, ",") {
+					t.Errorf("OutputPathError = %+v", pathErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateFiles() error = %v", err)
+			}
+			if len(generated) != 1 || generated[0].OutputPath != tt.want {
+				t.Errorf("GenerateFiles() = %+v, want %s", generated, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewGenerator().GenerateFiles(&config.Config{OutputDir: outputDir, AllowOutsideOutput: []string{"["}}); err == nil {
+		t.Error("GenerateFiles() expected error for invalid allow_outside_output pattern, got nil")
+	}
+}
+
+func TestDefaultPathProcessor_PathVariables(t *testing.T) {
+	vars := map[string]interface{}{
+		"project": map[string]interface{}{
+			"name":     "demo",
+			"go_mod":   "github.com/acme/demo",
+			"port":     8080,
+			"version":  1.5,
+			"enabled":  true,
+			"settings": map[string]interface{}{"a": 1},
+		},
+		"routes":       []interface{}{map[string]interface{}{"name": "users"}},
+		"service_name": "api",
+		"__profile":    "prod",
+		"a.b":          "flat",
+		"entity":       map[string]interface{}{"name": "UserProfile"},
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		strict  bool
+		want    string
+		wantErr string
+	}{
+		{name: "dotted path", path: "__project.name__/main.go", want: "demo/main.go"},
+		{name: "list index", path: "__routes.0.name__.go", want: "users.go"},
+		{name: "underscore in name", path: "cmd/__service_name__/__project.go_mod__", want: "cmd/api/github.com/acme/demo"},
+		{name: "escaped underscores", path: "__%5F%5Fprofile__.yaml", want: "prod.yaml"},
+		{name: "non-string scalars", path: "__project.port__-__project.version__-__project.enabled__", want: "8080-1.5-true"},
+		{name: "flat key with dot", path: "__a.b__.txt", want: "flat.txt"},
+		{name: "python dunder file", path: "pkg/__init__.py", want: "pkg/__init__.py"},
+		{name: "map value is kept", path: "__project.settings__.txt", want: "__project.settings__.txt"},
+		{name: "filters", path: "__entity.name|snake__.go/__entity.name|kebab__/__entity.name|camel__", want: "user_profile.go/user-profile/userProfile"},
+		{name: "chained filters", path: "__entity.name|plural|kebab__/__project.name|upper__", want: "user-profiles/DEMO"},
+		{name: "unknown filter", path: "__entity.name|snek__.go", wantErr: "未知的过滤器 snek"},
+		{name: "strict missing", path: "__project.nme__/__project.name__.txt", strict: true, wantErr: "project.nme: 变量不存在"},
+		{name: "strict map value", path: "__project.settings__.txt", strict: true, wantErr: "project.settings: 值不是字符串、数字或布尔值"},
+		{name: "strict invalid escape", path: "__a%zz__.txt", strict: true, wantErr: "a%zz: 转义无效"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &DefaultPathProcessor{Strict: tt.strict}
+			// 输出目录中的 __x__ 不会被替换
+			got, err := p.ProcessOutputPath(TemplateFile{RelativePath: filepath.FromSlash(tt.path) + ".tpl"}, "/out/__project.name__", vars)
+			if tt.wantErr != "" {
+				var varErr *PathVariableError
+				if !errors.As(err, &varErr) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ProcessOutputPath() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessOutputPath() error = %v", err)
+			}
+			if want := filepath.Join("/out/__project.name__", filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("ProcessOutputPath() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestGenerateFiles_StrictPathVariables(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "strict_path_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/__project.name__/__project.mod__.go.tpl": "ok",
+		"variables/variables.yaml":                          "project: {name: demo}",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+	}
+
+	// 默认只警告并保留原始字符串
+	generated, err := NewGenerator().GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	if want := filepath.Join(cfg.OutputDir, "demo", "__project.mod__.go"); len(generated) != 1 || generated[0].OutputPath != want {
+		t.Errorf("GenerateFiles() = %+v, want %s", generated, want)
+	}
+
+	cfg.StrictPathVariables = true
+	_, err = NewGenerator().GenerateFiles(cfg)
+	var varErr *PathVariableError
+	if !errors.As(err, &varErr) || !reflect.DeepEqual(varErr.Unresolved, []string{"project.mod: 变量不存在"}) {
+		t.Fatalf("GenerateFiles() error = %v, want *PathVariableError for project.mod", err)
+	}
+}
@@ -0,0 +1,295 @@
+package generator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/clh021/generator/pkg/utils"
+	"github.com/clh021/generator/pkg/variables"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// QuestionsFileName 模板目录中声明需要询问的变量的文件
+const QuestionsFileName = ".gen_questions.yaml"
+
+// AnswersFileName 保存交互输入结果的变量文件，位于变量目录中，下次生成时作为普通变量文件加载
+const AnswersFileName = "answers.yaml"
+
+// Question 一个需要用户提供的变量
+//
+//	questions:
+//	  - name: project.name
+//	    description: 项目名称
+//	    pattern: "^[a-z][a-z0-9-]*$"
+//	  - name: project.type
+//	    description: 项目类型
+//	    choices: [service, library]
+//	    default: service
+//	  - name: project.port
+//	    type: integer
+//	    default: 8080
+type Question struct {
+	// 变量路径，如 project.name
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// 值的类型: string(默认), integer, number, boolean, list（逗号分隔的字符串列表）
+	Type    string        `yaml:"type"`
+	Default interface{}   `yaml:"default"`
+	Choices []interface{} `yaml:"choices"`
+	// 字符串需要匹配的正则表达式
+	Pattern string `yaml:"pattern"`
+	// 为 true 时允许不提供值
+	Optional bool `yaml:"optional"`
+
+	pattern *regexp.Regexp
+}
+
+// questionsFile 对应问题文件的结构
+type questionsFile struct {
+	Questions []Question `yaml:"questions"`
+}
+
+// LoadQuestions 加载问题文件
+func LoadQuestions(path string) ([]Question, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取问题文件 %s 失败", path)
+	}
+
+	var file questionsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, errors.Wrapf(err, "解析问题文件 %s 失败", path)
+	}
+	for i := range file.Questions {
+		q := &file.Questions[i]
+		if q.Name == "" {
+			return nil, errors.Errorf("问题文件 %s 中第 %d 个问题缺少 name", path, i+1)
+		}
+		switch q.Type {
+		case "", "string", "integer", "number", "boolean", "list":
+		default:
+			return nil, errors.Errorf("问题 %s 的类型 %s 无效", q.Name, q.Type)
+		}
+		if q.Pattern != "" {
+			if q.pattern, err = regexp.Compile(q.Pattern); err != nil {
+				return nil, errors.Wrapf(err, "问题 %s 的 pattern 无效", q.Name)
+			}
+		}
+	}
+	return file.Questions, nil
+}
+
+// Label 返回提示用户时显示的文字
+func (q Question) Label() string {
+	if q.Description != "" {
+		return fmt.Sprintf("%s (%s)", q.Description, q.Name)
+	}
+	return q.Name
+}
+
+// DefaultString 返回默认值的字符串形式，没有默认值时为空字符串
+func (q Question) DefaultString() string {
+	switch d := q.Default.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(d))
+		for i, item := range d {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(d)
+	}
+}
+
+// Parse 将用户的输入转换为变量值并校验，输入为空时使用默认值
+// 返回的 ok 为 false 表示可选的问题没有提供值
+func (q Question) Parse(input string) (value interface{}, ok bool, err error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		input = q.DefaultString()
+	}
+	if input == "" {
+		if q.Optional {
+			return nil, false, nil
+		}
+		return nil, false, errors.Errorf("%s 不能为空", q.Name)
+	}
+
+	if len(q.Choices) > 0 {
+		found := false
+		for _, choice := range q.Choices {
+			if fmt.Sprint(choice) == input {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false, errors.Errorf("%s 只能是 %s 之一", q.Name, strings.Join(q.choiceStrings(), ", "))
+		}
+	}
+
+	switch q.Type {
+	case "integer":
+		i, err := strconv.Atoi(input)
+		if err != nil {
+			return nil, false, errors.Errorf("%s 应为整数: %s", q.Name, input)
+		}
+		return i, true, nil
+	case "number":
+		f, err := strconv.ParseFloat(input, 64)
+		if err != nil {
+			return nil, false, errors.Errorf("%s 应为数字: %s", q.Name, input)
+		}
+		return f, true, nil
+	case "boolean":
+		switch strings.ToLower(input) {
+		case "y", "yes", "true", "1":
+			return true, true, nil
+		case "n", "no", "false", "0":
+			return false, true, nil
+		}
+		return nil, false, errors.Errorf("%s 应为 yes 或 no: %s", q.Name, input)
+	case "list":
+		var items []interface{}
+		for _, item := range strings.Split(input, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, true, nil
+	}
+
+	if q.pattern != nil && !q.pattern.MatchString(input) {
+		return nil, false, errors.Errorf("%s 不匹配 %s: %s", q.Name, q.Pattern, input)
+	}
+	return input, true, nil
+}
+
+func (q Question) choiceStrings() []string {
+	choices := make([]string, len(q.Choices))
+	for i, choice := range q.Choices {
+		choices[i] = fmt.Sprint(choice)
+	}
+	return choices
+}
+
+// Prompter 向用户询问变量文件中没有提供的变量
+type Prompter interface {
+	// Ask 返回用户对问题的回答，ok 为 false 表示可选的问题没有提供值
+	Ask(q Question) (value interface{}, ok bool, err error)
+}
+
+// TerminalPrompter 在终端中逐个询问，回答无效时重新询问
+type TerminalPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewTerminalPrompter 创建终端询问器，提示输出到 out
+func NewTerminalPrompter(in io.Reader, out io.Writer) *TerminalPrompter {
+	return &TerminalPrompter{in: bufio.NewReader(in), out: out}
+}
+
+// Ask 实现 Prompter 接口
+func (p *TerminalPrompter) Ask(q Question) (interface{}, bool, error) {
+	for {
+		var input string
+		var err error
+		if len(q.Choices) > 0 {
+			input, err = utils.PromptChoice(p.in, p.out, q.Label(), q.choiceStrings(), q.DefaultString())
+		} else {
+			input, err = utils.PromptInput(p.in, p.out, q.Label(), q.DefaultString())
+		}
+		if errors.Is(err, io.EOF) {
+			return nil, false, errors.Wrapf(err, "读取 %s 的输入失败", q.Name)
+		}
+		if err == nil {
+			value, ok, parseErr := q.Parse(input)
+			if parseErr == nil {
+				return value, ok, nil
+			}
+			err = parseErr
+		}
+		fmt.Fprintf(p.out, "%v，请重新输入\n", err)
+	}
+}
+
+// FindQuestionsFile 返回模板目录中的问题文件，不存在时返回空字符串
+func FindQuestionsFile(templateDir string) string {
+	path := filepath.Join(templateDir, QuestionsFileName)
+	if fileExists(path) {
+		return path
+	}
+	return ""
+}
+
+// askQuestions 询问 vars 中缺少的变量，返回回答组成的变量树
+// prompter 为 nil（非交互模式）时使用默认值，没有默认值的必需变量作为错误返回
+func askQuestions(questions []Question, vars map[string]interface{}, prompter Prompter) (map[string]interface{}, error) {
+	answers := make(map[string]interface{})
+	var missing []string
+	for _, q := range questions {
+		if _, ok := variables.Get(vars, q.Name); ok {
+			continue
+		}
+
+		var value interface{}
+		var ok bool
+		var err error
+		if prompter != nil {
+			value, ok, err = prompter.Ask(q)
+			if err != nil {
+				return nil, err
+			}
+		} else if value, ok, err = q.Parse(""); err != nil {
+			missing = append(missing, q.Label())
+			continue
+		}
+		if ok {
+			if err := variables.ApplyOverrides(answers, []variables.Override{{Path: q.Name, Value: value}}, variables.MergeOptions{}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.Errorf("缺少必需的变量: %s；请在变量文件中提供，或在终端中运行以交互输入", strings.Join(missing, ", "))
+	}
+	return answers, nil
+}
+
+// saveAnswers 将回答合并写入变量目录中的回答文件
+func saveAnswers(variablesDir string, answers map[string]interface{}) (string, error) {
+	path := filepath.Join(variablesDir, AnswersFileName)
+	existing := make(map[string]interface{})
+	if fileExists(path) {
+		vars, err := variables.DecodeFile(path)
+		if err != nil {
+			return "", err
+		}
+		if vars != nil {
+			existing = vars
+		}
+	}
+	existing = variables.Merge(existing, answers, variables.MergeOptions{})
+
+	data, err := yaml.Marshal(existing)
+	if err != nil {
+		return "", errors.Wrap(err, "序列化回答失败")
+	}
+	if err := os.MkdirAll(variablesDir, 0755); err != nil {
+		return "", errors.Wrapf(err, "创建变量目录 %s 失败", variablesDir)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", errors.Wrapf(err, "写入回答文件 %s 失败", path)
+	}
+	return path, nil
+}
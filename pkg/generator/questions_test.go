@@ -0,0 +1,194 @@
+package generator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/clh021/generator/pkg/config"
+	"github.com/clh021/generator/pkg/variables"
+)
+
+const testQuestions = `questions:
+  - name: name
+    description: 项目名称
+    pattern: "^[a-z][a-z0-9-]*$"
+  - name: project.type
+    choices: [service, library]
+    default: service
+  - name: project.port
+    type: integer
+    default: 8080
+  - name: project.tags
+    type: list
+    optional: true
+`
+
+func TestQuestion_Parse(t *testing.T) {
+	tests := []struct {
+		name     string
+		question Question
+		input    string
+		want     interface{}
+		wantOK   bool
+		wantErr  bool
+	}{
+		{name: "string", question: Question{Name: "a"}, input: " demo ", want: "demo", wantOK: true},
+		{name: "default", question: Question{Name: "a", Default: "x"}, input: "", want: "x", wantOK: true},
+		{name: "required", question: Question{Name: "a"}, input: "", wantErr: true},
+		{name: "optional", question: Question{Name: "a", Optional: true}, input: ""},
+		{name: "integer", question: Question{Name: "a", Type: "integer"}, input: "80", want: 80, wantOK: true},
+		{name: "integer default", question: Question{Name: "a", Type: "integer", Default: 8080}, input: "", want: 8080, wantOK: true},
+		{name: "invalid integer", question: Question{Name: "a", Type: "integer"}, input: "eighty", wantErr: true},
+		{name: "number", question: Question{Name: "a", Type: "number"}, input: "0.5", want: 0.5, wantOK: true},
+		{name: "boolean", question: Question{Name: "a", Type: "boolean"}, input: "Yes", want: true, wantOK: true},
+		{name: "invalid boolean", question: Question{Name: "a", Type: "boolean"}, input: "maybe", wantErr: true},
+		{name: "list", question: Question{Name: "a", Type: "list", Default: []interface{}{"x"}}, input: "a, b,", want: []interface{}{"a", "b"}, wantOK: true},
+		{name: "choice", question: Question{Name: "a", Choices: []interface{}{"x", "y"}}, input: "y", want: "y", wantOK: true},
+		{name: "invalid choice", question: Question{Name: "a", Choices: []interface{}{"x", "y"}}, input: "z", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := tt.question.Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, %v, want %#v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLoadQuestions(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "questions_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"valid.yaml":       testQuestions,
+		"no_name.yaml":     "questions:\n  - description: x\n",
+		"bad_type.yaml":    "questions:\n  - {name: a, type: date}\n",
+		"bad_pattern.yaml": "questions:\n  - {name: a, pattern: \"[\"}\n",
+	})
+
+	questions, err := LoadQuestions(filepath.Join(tempDir, "valid.yaml"))
+	if err != nil {
+		t.Fatalf("LoadQuestions() error = %v", err)
+	}
+	if len(questions) != 4 || questions[0].Label() != "项目名称 (name)" {
+		t.Fatalf("LoadQuestions() = %+v", questions)
+	}
+	// pattern 在加载时编译
+	if _, _, err := questions[0].Parse("Demo"); err == nil {
+		t.Error("Parse() expected pattern error, got nil")
+	}
+
+	for _, name := range []string{"no_name.yaml", "bad_type.yaml", "bad_pattern.yaml"} {
+		if _, err := LoadQuestions(filepath.Join(tempDir, name)); err == nil {
+			t.Errorf("LoadQuestions(%s) expected error, got nil", name)
+		}
+	}
+}
+
+func TestTerminalPrompter_Ask(t *testing.T) {
+	// 无效的回答会重新询问
+	var out bytes.Buffer
+	prompter := NewTerminalPrompter(strings.NewReader("\nDemo\ndemo\n3\n2\n"), &out)
+
+	name := Question{Name: "name", Pattern: "^[a-z]+$", pattern: regexp.MustCompile("^[a-z]+$")}
+	value, ok, err := prompter.Ask(name)
+	if err != nil || !ok || value != "demo" {
+		t.Fatalf("Ask() = %v, %v, %v, want demo", value, ok, err)
+	}
+	if got := strings.Count(out.String(), "请重新输入"); got != 2 {
+		t.Errorf("re-prompted %d times, want 2\n%s", got, out.String())
+	}
+
+	kind := Question{Name: "type", Choices: []interface{}{"service", "library"}}
+	value, _, err = prompter.Ask(kind)
+	if err != nil || value != "library" {
+		t.Fatalf("Ask() = %v, %v, want library", value, err)
+	}
+
+	// 输入结束时返回错误而不是无限重试
+	if _, _, err := prompter.Ask(name); err == nil {
+		t.Error("Ask() expected error at end of input, got nil")
+	}
+}
+
+// fakePrompter 按变量路径返回预设的回答，并记录询问过的问题
+type fakePrompter struct {
+	answers map[string]interface{}
+	asked   []string
+}
+
+func (p *fakePrompter) Ask(q Question) (interface{}, bool, error) {
+	p.asked = append(p.asked, q.Name)
+	value, ok := p.answers[q.Name]
+	return value, ok, nil
+}
+
+func TestGenerateFiles_Questions(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "generate_questions_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/__name__.txt.tpl":     "{{ .name }}:{{ .project.type }}:{{ .project.port }}",
+		"templates/" + QuestionsFileName: testQuestions,
+		"variables/variables.yaml":       "project:\n  type: library\n",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+	}
+
+	// 非交互模式下缺少没有默认值的必需变量时报错
+	if _, err := NewGenerator().GenerateFiles(cfg); err == nil || !strings.Contains(err.Error(), "项目名称 (name)") {
+		t.Fatalf("GenerateFiles() error = %v, want missing name", err)
+	}
+
+	// 只询问缺少的变量，回答同时用于路径和模板
+	prompter := &fakePrompter{answers: map[string]interface{}{"name": "demo", "project.port": 9000}}
+	cfg.SaveAnswers = true
+	files, err := NewGenerator().WithPrompter(prompter).GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	wantAsked := []string{"name", "project.port", "project.tags"}
+	if !reflect.DeepEqual(prompter.asked, wantAsked) {
+		t.Errorf("asked = %v, want %v", prompter.asked, wantAsked)
+	}
+	if len(files) != 1 || filepath.Base(files[0].OutputPath) != "demo.txt" || files[0].Content != "demo:library:9000" {
+		t.Fatalf("GenerateFiles() = %+v, want demo.txt with demo:library:9000", files)
+	}
+
+	// 保存的回答在下次生成时作为普通变量文件加载，不再询问
+	saved, err := variables.DecodeFile(filepath.Join(cfg.VariablesDir, AnswersFileName))
+	if err != nil {
+		t.Fatalf("DecodeFile() error = %v", err)
+	}
+	wantSaved := map[string]interface{}{"name": "demo", "project": map[string]interface{}{"port": 9000}}
+	if !reflect.DeepEqual(saved, wantSaved) {
+		t.Errorf("saved answers = %v, want %v", saved, wantSaved)
+	}
+	cfg.SaveAnswers = false
+	files, err = NewGenerator().GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() replay error = %v", err)
+	}
+	if files[0].Content != "demo:library:9000" {
+		t.Errorf("Content = %q, want %q", files[0].Content, "demo:library:9000")
+	}
+}
@@ -0,0 +1,50 @@
+package generator
+
+import (
+	"path/filepath"
+
+	"github.com/clh021/generator/pkg/variables"
+)
+
+// SchemaFileNames 模板目录中的变量校验规则文件名，按顺序查找第一个存在的文件
+var SchemaFileNames = []string{".gen_schema.yaml", ".gen_schema.yml", ".gen_schema.json"}
+
+// FindSchemaFile 返回模板目录中的变量校验规则文件，不存在时返回空字符串
+func FindSchemaFile(templateDir string) string {
+	for _, name := range SchemaFileNames {
+		path := filepath.Join(templateDir, name)
+		if fileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// isSchemaFile 判断模板相对路径是否为模板目录根部的校验规则文件
+func isSchemaFile(slashPath string) bool {
+	for _, name := range SchemaFileNames {
+		if slashPath == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateVariables 校验变量，失败时返回 *variables.ValidationError
+// 每处错误的来源为变量路径或其最近的上级路径的来源，错误信息中机密变量的值会被隐藏
+func validateVariables(schema *variables.Schema, vars map[string]interface{}, provenance *variables.Provenance, secrets *variables.Secrets) error {
+	violations := schema.Validate(vars)
+	if len(violations) == 0 {
+		return nil
+	}
+	for i := range violations {
+		violations[i].Message = secrets.RedactText(vars, violations[i].Message)
+		for p := violations[i].Path; p != ""; p = variables.ParentPath(p) {
+			if origin, ok := provenance.Lookup(p); ok {
+				violations[i].Origin = &origin
+				break
+			}
+		}
+	}
+	return &variables.ValidationError{Violations: violations}
+}
@@ -0,0 +1,152 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/clh021/generator/pkg/config"
+	"github.com/clh021/generator/pkg/variables"
+)
+
+func TestGenerateFiles_SchemaValidation(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "schema_validation_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/__name__.txt.tpl": "{{ .name }}:{{ .port }}",
+		"templates/.gen_schema.yaml": "type: object\nrequired: [name]\nproperties:\n  name: {type: string}\n  port: {type: integer, default: 8080}\n  debug: {type: boolean}\n",
+		"variables/a.yaml":           "name: demo\ndebug: false\n",
+		"variables/b.yaml":           "other: 1\ndebug: \"yes\"\n",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+	}
+
+	// 校验错误报告变量路径以及最终生效的来源位置
+	_, err = NewGenerator().GenerateFiles(cfg)
+	var validationErr *variables.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("GenerateFiles() error = %v, want *variables.ValidationError", err)
+	}
+	want := []variables.Violation{{
+		Path:    "debug",
+		Message: "类型应为 boolean，实际为 string",
+		Origin:  &variables.Origin{File: filepath.Join(cfg.VariablesDir, "b.yaml"), Line: 2, Column: 1},
+	}}
+	if !reflect.DeepEqual(validationErr.Violations, want) {
+		t.Errorf("Violations = %v, want %v", validationErr.Violations, want)
+	}
+
+	// 命令行覆盖的值报告为 -set 来源
+	overrides, _ := variables.ParseSet("debug=1")
+	_, err = NewGenerator().WithVariableOverrides(overrides).GenerateFiles(cfg)
+	if !errors.As(err, &validationErr) || validationErr.Violations[0].Origin.File != "-set debug" {
+		t.Fatalf("GenerateFiles() error = %v, want violation from -set", err)
+	}
+
+	// 修正后默认值生效，校验规则文件本身不会被当作模板
+	writeTestFiles(t, tempDir, map[string]string{"variables/b.yaml": "other: 1\n"})
+	files, err := NewGenerator().GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	if len(files) != 1 || files[0].Content != "demo:8080" {
+		t.Errorf("GenerateFiles() = %+v, want one file with content %q", files, "demo:8080")
+	}
+}
+
+func TestGenerateFiles_SchemaFileFromConfig(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "schema_config_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/a.txt.tpl":      "{{ .name }}",
+		"variables/variables.yaml": "name: demo\n",
+		"schema.json":              `{"required": ["version"]}`,
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+		SchemaFile:   filepath.Join(tempDir, "schema.json"),
+	}
+
+	_, err = NewGenerator().GenerateFiles(cfg)
+	var validationErr *variables.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Violations) != 1 || validationErr.Violations[0].Path != "version" {
+		t.Fatalf("GenerateFiles() error = %v, want missing version", err)
+	}
+	// 缺少的顶层变量没有来源位置
+	if validationErr.Violations[0].Origin != nil {
+		t.Errorf("Origin = %v, want nil", validationErr.Violations[0].Origin)
+	}
+}
+
+func TestGenerator_Secrets(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "secrets_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/a.txt.tpl":        "{{ .db.dsn }}",
+		"templates/.gen_schema.yaml": "properties:\n  signing: {secret: true}\n  db: {properties: {port: {type: integer}}}\n",
+		"variables/a.yaml":           "db: {user: app, password: hunter22, dsn: !secret \"postgres://app@db\", port: 5432}\nsigning: {key: abcd1234}\n",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+	}
+
+	// 键名模式、!secret 标签和校验规则中的 secret: true 都会标记机密变量
+	gen := NewGenerator()
+	vars, _, err := gen.Variables(cfg)
+	if err != nil {
+		t.Fatalf("Variables() error = %v", err)
+	}
+	want := map[string]interface{}{
+		"db":         map[string]interface{}{"user": "app", "password": "******", "dsn": "******", "port": 5432},
+		"signing":    "******",
+		"__profile":  "",
+		"__profiles": []interface{}{},
+	}
+	if got := gen.Secrets().Redact(vars); !reflect.DeepEqual(got, want) {
+		t.Errorf("Redact() = %v, want %v", got, want)
+	}
+
+	// secret_keys 替换默认的键名模式
+	cfg.SecretKeys = []string{"user"}
+	if _, _, err := gen.Variables(cfg); err != nil {
+		t.Fatalf("Variables() error = %v", err)
+	}
+	if !gen.Secrets().IsSecret("db.user") || gen.Secrets().IsSecret("db.password") {
+		t.Errorf("Secrets() with secret_keys = [user] marks %v", gen.Secrets().Paths())
+	}
+
+	// 校验错误中不包含机密的值
+	cfg.SecretKeys = nil
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/.gen_schema.yaml": "properties:\n  db: {properties: {password: {pattern: \"^[A-Z]\"}}}\n",
+	})
+	_, err = NewGenerator().GenerateFiles(cfg)
+	var validationErr *variables.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("GenerateFiles() error = %v, want *variables.ValidationError", err)
+	}
+	if msg := validationErr.Violations[0].Message; msg != `值 "******" 不匹配 ^[A-Z]` {
+		t.Errorf("Violation message = %q", msg)
+	}
+}
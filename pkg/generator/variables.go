@@ -3,8 +3,11 @@ package generator
 import (
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/clh021/generator/internal/template"
+	"github.com/clh021/generator/pkg/variables"
 	"github.com/pkg/errors"
 )
 
@@ -16,11 +19,27 @@ type VariableLoader interface {
 	FindVariableFiles(variablesDir string, additionalFiles []string) ([]string, error)
 }
 
+// 变量目录中的公共变量和变量配置（profile）子目录
+const (
+	BaseVariablesDir = "base"
+	ProfilesDir      = "profiles"
+)
+
+// 模板中可用的保留变量：当前的变量配置名称（最后一个）和所有变量配置名称（按叠加顺序）
+const (
+	ProfileVariable  = "__profile"
+	ProfilesVariable = "__profiles"
+)
+
 // DefaultVariableLoader 默认的变量加载器实现
 type DefaultVariableLoader struct {
 	TemplateDir  string
 	VariablesDir string
 	OutputDir    string
+	// 合并多个变量文件时的选项
+	MergeOptions variables.MergeOptions
+	// 按顺序叠加的变量配置，对应变量目录中的 profiles/<名称>/
+	Profiles []string
 }
 
 // NewDefaultVariableLoader 创建默认的变量加载器
@@ -34,61 +53,169 @@ func NewDefaultVariableLoader(templateDir, variablesDir, outputDir string) *Defa
 
 // LoadVariables 加载变量文件并返回变量映射
 func (l *DefaultVariableLoader) LoadVariables(variablesDir string, additionalFiles []string) (map[string]interface{}, error) {
+	vars, _, err := l.LoadVariablesWithProvenance(variablesDir, additionalFiles)
+	return vars, err
+}
+
+// LoadVariablesWithProvenance 与 LoadVariables 相同，同时返回每个变量值来自哪个文件的哪一行
+func (l *DefaultVariableLoader) LoadVariablesWithProvenance(variablesDir string, additionalFiles []string) (map[string]interface{}, *variables.Provenance, error) {
 	// 检查变量目录是否存在
 	if _, err := os.Stat(variablesDir); os.IsNotExist(err) && len(additionalFiles) == 0 {
-		return nil, errors.Wrapf(err, "变量目录不存在且未指定变量文件: %s", variablesDir)
+		return nil, nil, errors.Wrapf(err, "变量目录不存在且未指定变量文件: %s", variablesDir)
 	}
 
 	// 查找变量文件
 	variableFiles, err := l.FindVariableFiles(variablesDir, additionalFiles)
 	if err != nil {
-		return nil, errors.Wrap(err, "查找变量文件失败")
+		return nil, nil, errors.Wrap(err, "查找变量文件失败")
 	}
 
 	// 检查是否有可用的变量文件
 	if len(variableFiles) == 0 {
-		return nil, errors.New("没有找到可用的变量文件")
+		return nil, nil, errors.New("没有找到可用的变量文件")
 	}
 
 	// 创建模板引擎
-	engine := template.New(l.TemplateDir, l.VariablesDir, l.OutputDir)
+	engine := template.New(l.TemplateDir, l.VariablesDir, l.OutputDir).WithMergeOptions(l.MergeOptions)
 
 	// 加载变量
 	if err := engine.LoadVariables(variableFiles); err != nil {
-		return nil, errors.Wrap(err, "加载变量失败")
+		return nil, nil, errors.Wrap(err, "加载变量失败")
 	}
 
 	// 获取变量
-	return engine.GetVariables(), nil
+	return engine.GetVariables(), engine.Provenance(), nil
 }
 
-// FindVariableFiles 查找变量文件
+// FindVariableFiles 查找变量文件，返回的顺序即合并顺序（后面的文件覆盖前面的）：
+//  1. 变量目录的 base/ 子目录中的文件
+//  2. 变量目录中的文件
+//  3. 每个变量配置的 profiles/<名称>/ 子目录中的文件，按 Profiles 的顺序
+//  4. 额外指定的文件，按指定顺序，可以用 @格式 后缀指定文件格式，如 vars.txt@toml
+//
+// 目录中只加载扩展名已注册的文件（*.yaml、*.yml、*.json、*.toml、*.env 等，见 variables.RegisterDecoder），按文件名排序。
+// 同一个文件出现多次时只保留最后一次出现的位置，因此额外指定目录中的文件可以调整其合并顺序
 func (l *DefaultVariableLoader) FindVariableFiles(variablesDir string, additionalFiles []string) ([]string, error) {
 	var files []string
 
 	// 加载目录中的文件
-	if variablesDir != "" && dirExists(variablesDir) {
-		yamlFiles, err := filepath.Glob(filepath.Join(variablesDir, "*.yaml"))
+	if variablesDir != "" {
+		for _, dir := range []string{filepath.Join(variablesDir, BaseVariablesDir), variablesDir} {
+			matches, err := findVariableFilesIn(dir)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+
+	// 叠加变量配置
+	for _, profile := range l.Profiles {
+		dir, err := profileDir(variablesDir, profile)
 		if err != nil {
-			return nil, errors.Wrap(err, "查找 *.yaml 变量文件失败")
+			return nil, err
 		}
-		ymlFiles, err := filepath.Glob(filepath.Join(variablesDir, "*.yml"))
+		matches, err := findVariableFilesIn(dir)
 		if err != nil {
-			return nil, errors.Wrap(err, "查找 *.yml 变量文件失败")
+			return nil, err
 		}
-		files = append(yamlFiles, ymlFiles...)
+		files = append(files, matches...)
 	}
 
 	// 添加额外的文件
 	for _, file := range additionalFiles {
-		if fileExists(file) {
+		if path, _ := variables.SplitFormat(file); fileExists(path) {
 			files = append(files, file)
 		}
 	}
 
+	return dedupeKeepLast(files), nil
+}
+
+// findVariableFilesIn 返回目录中扩展名已注册的变量文件，按文件名排序，目录不存在时返回空列表
+func findVariableFilesIn(dir string) ([]string, error) {
+	if !dirExists(dir) {
+		return nil, nil
+	}
+	var files []string
+	for _, ext := range variables.Extensions() {
+		matches, err := filepath.Glob(filepath.Join(dir, "*."+ext))
+		if err != nil {
+			return nil, errors.Wrapf(err, "查找 *.%s 变量文件失败", ext)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
 	return files, nil
 }
 
+// profileDir 返回变量配置的目录，名称无效或目录不存在时返回错误并列出可用的变量配置
+func profileDir(variablesDir, profile string) (string, error) {
+	if profile == "" || profile == "." || profile == ".." || strings.ContainsAny(profile, `/\`) {
+		return "", errors.Errorf("无效的变量配置名称: %q", profile)
+	}
+	dir := filepath.Join(variablesDir, ProfilesDir, profile)
+	if dirExists(dir) {
+		return dir, nil
+	}
+	available := ListProfiles(variablesDir)
+	if len(available) == 0 {
+		return "", errors.Errorf("变量配置 %s 不存在: %s 中没有任何变量配置", profile, filepath.Join(variablesDir, ProfilesDir))
+	}
+	return "", errors.Errorf("变量配置 %s 不存在，可用的变量配置: %s", profile, strings.Join(available, ", "))
+}
+
+// ListProfiles 返回变量目录的 profiles/ 中可用的变量配置名称，按名称排序
+func ListProfiles(variablesDir string) []string {
+	entries, err := os.ReadDir(filepath.Join(variablesDir, ProfilesDir))
+	if err != nil {
+		return nil
+	}
+	var profiles []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+	return profiles
+}
+
+// profileVariables 返回保留变量 __profile 和 __profiles，未使用变量配置时分别为空字符串和空列表
+func profileVariables(profiles []string) map[string]interface{} {
+	names := make([]interface{}, len(profiles))
+	current := ""
+	for i, profile := range profiles {
+		names[i] = profile
+		current = profile
+	}
+	return map[string]interface{}{
+		ProfileVariable:  current,
+		ProfilesVariable: names,
+	}
+}
+
+// dedupeKeepLast 去除重复的文件路径，保留每个文件最后一次出现的位置
+func dedupeKeepLast(files []string) []string {
+	last := make(map[string]int, len(files))
+	keys := make([]string, len(files))
+	for i, file := range files {
+		path, _ := variables.SplitFormat(file)
+		keys[i] = path
+		if abs, err := filepath.Abs(path); err == nil {
+			keys[i] = abs
+		}
+		last[keys[i]] = i
+	}
+
+	var result []string
+	for i, file := range files {
+		if last[keys[i]] == i {
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
 // 辅助函数：检查目录是否存在
 func dirExists(path string) bool {
 	info, err := os.Stat(path)
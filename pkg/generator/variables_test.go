@@ -4,7 +4,12 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/clh021/generator/internal/template"
+	"github.com/clh021/generator/pkg/config"
+	"github.com/clh021/generator/pkg/variables"
 )
 
 func TestDefaultVariableLoader_FindVariableFiles(t *testing.T) {
@@ -353,3 +358,344 @@ func TestFileExists(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultVariableLoader_FindVariableFilesOrder(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "variable_order_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"b.yml":       "v: b",
+		"a.yaml":      "v: a",
+		"c.yaml":      "v: c",
+		"extra.yaml2": "v: extra",
+	})
+
+	// 目录中的文件按文件名排序，额外文件按指定顺序，重复文件保留最后一次出现的位置
+	loader := NewDefaultVariableLoader("", "", "")
+	files, err := loader.FindVariableFiles(tempDir, []string{filepath.Join(tempDir, "extra.yaml2"), filepath.Join(tempDir, "a.yaml")})
+	if err != nil {
+		t.Fatalf("FindVariableFiles() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(tempDir, "b.yml"),
+		filepath.Join(tempDir, "c.yaml"),
+		filepath.Join(tempDir, "extra.yaml2"),
+		filepath.Join(tempDir, "a.yaml"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("FindVariableFiles() = %v, want %v", files, want)
+	}
+}
+
+func TestDefaultVariableLoader_FindVariableFilesProfiles(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "variable_profiles_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"base/b.yaml":                 "v: base-b",
+		"base/a.yaml":                 "v: base-a",
+		"local.yaml":                  "v: local",
+		"profiles/prod/app.yaml":      "v: prod",
+		"profiles/prod/db.toml":       "v = 'prod-db'",
+		"profiles/eu/app.yaml":        "v: eu",
+		"profiles/staging/a.yaml":     "v: staging",
+		"profiles/prod/notes.txt":     "not a variable file",
+		"profiles/prod/nested/x.yaml": "v: nested",
+	})
+
+	// base/ < 变量目录 < 按顺序叠加的变量配置 < 额外文件
+	loader := NewDefaultVariableLoader("", "", "")
+	loader.Profiles = []string{"prod", "eu"}
+	files, err := loader.FindVariableFiles(tempDir, []string{filepath.Join(tempDir, "base/a.yaml")})
+	if err != nil {
+		t.Fatalf("FindVariableFiles() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(tempDir, "base/b.yaml"),
+		filepath.Join(tempDir, "local.yaml"),
+		filepath.Join(tempDir, "profiles/prod/app.yaml"),
+		filepath.Join(tempDir, "profiles/prod/db.toml"),
+		filepath.Join(tempDir, "profiles/eu/app.yaml"),
+		filepath.Join(tempDir, "base/a.yaml"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("FindVariableFiles() = %v, want %v", files, want)
+	}
+
+	// 不存在的变量配置报错并列出可用的变量配置
+	loader.Profiles = []string{"dev"}
+	_, err = loader.FindVariableFiles(tempDir, nil)
+	if err == nil || !strings.Contains(err.Error(), "eu, prod, staging") {
+		t.Errorf("FindVariableFiles() error = %v, want available profiles", err)
+	}
+	loader.Profiles = []string{"../base"}
+	if _, err := loader.FindVariableFiles(tempDir, nil); err == nil {
+		t.Error("FindVariableFiles() expected error for invalid profile name, got nil")
+	}
+}
+
+func TestGenerateFiles_Profiles(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "generate_profiles_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/config.txt.tpl":          "{{ .__profile }} {{ .__profiles }} {{ .replicas }} {{ .region }}",
+		"variables/base/app.yaml":           "replicas: 1\nregion: local\n",
+		"variables/profiles/prod/app.yaml":  "replicas: 3\nregion: us\n",
+		"variables/profiles/eu/region.yaml": "region: eu\n",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+	}
+
+	tests := []struct {
+		profiles []string
+		want     string
+	}{
+		{nil, " [] 1 local"},
+		{[]string{"prod"}, "prod [prod] 3 us"},
+		{[]string{"prod", "eu"}, "eu [prod eu] 3 eu"},
+	}
+	for _, tt := range tests {
+		cfg.Profiles = tt.profiles
+		files, err := NewGenerator().GenerateFiles(cfg)
+		if err != nil {
+			t.Fatalf("GenerateFiles(%v) error = %v", tt.profiles, err)
+		}
+		if len(files) != 1 || files[0].Content != tt.want {
+			t.Errorf("GenerateFiles(%v) = %+v, want %q", tt.profiles, files, tt.want)
+		}
+	}
+}
+
+func TestDefaultVariableLoader_LoadVariablesDeepMerge(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "variable_merge_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"common.yaml":   "database:\n  host: localhost\n  options:\n    ssl: false\n    pool: 5\nroutes:\n  - {name: users, path: /users}\n",
+		"env-prod.yaml": "database:\n  options:\n    ssl: true\n    pool: ~delete\nroutes:\n  - {name: users, auth: true}\n  - {name: health, path: /health}\n",
+	})
+
+	loader := NewDefaultVariableLoader("", "", "")
+	loader.MergeOptions, err = variables.NewMergeOptions("", map[string]string{"routes": "merge-by-key"})
+	if err != nil {
+		t.Fatalf("NewMergeOptions() error = %v", err)
+	}
+	vars, err := loader.LoadVariables(tempDir, nil)
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"database": map[string]interface{}{
+			"host":    "localhost",
+			"options": map[string]interface{}{"ssl": true},
+		},
+		"routes": []interface{}{
+			map[string]interface{}{"name": "users", "path": "/users", "auth": true},
+			map[string]interface{}{"name": "health", "path": "/health"},
+		},
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("LoadVariables() = %v, want %v", vars, want)
+	}
+}
+
+func TestGenerator_VariableOverrides(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "variable_overrides_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/__name__.txt.tpl": "{{ .name }} {{ .project.name }} {{ .project.version }} {{ range .tags }}{{ . }}{{ end }}",
+		"variables/variables.yaml":   "name: old\nproject:\n  name: Old\n  version: 1\ntags: [a]\n",
+	})
+
+	overrides, err := variables.ParseSet("name=app,project.name=Foo")
+	if err != nil {
+		t.Fatalf("ParseSet() error = %v", err)
+	}
+	jsonOverrides, err := variables.ParseSetJSON(`tags=["x","y"]`)
+	if err != nil {
+		t.Fatalf("ParseSetJSON() error = %v", err)
+	}
+
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+	}
+	files, err := NewGenerator().WithVariableOverrides(append(overrides, jsonOverrides...)).GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("GenerateFiles() returned %d files, want 1", len(files))
+	}
+
+	// 覆盖值同时作用于输出路径和模板内容，未覆盖的值保持不变
+	wantPath := filepath.Join(cfg.OutputDir, "app.txt")
+	wantContent := "app Foo 1 xy"
+	if files[0].OutputPath != wantPath || files[0].Content != wantContent {
+		t.Errorf("file = %s %q, want %s %q", files[0].OutputPath, files[0].Content, wantPath, wantContent)
+	}
+}
+
+func TestDefaultVariableLoader_LoadVariablesFormats(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "variable_formats_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"vars/a.yaml":    "name: yaml\ndb:\n  host: localhost\n",
+		"vars/b.json":    `{"name": "json", "db": {"port": 5432}}`,
+		"vars/c.toml":    "version = \"1.0\"\n\n[db]\nssl = true\n",
+		"vars/d.env":     "TOKEN=secret\n",
+		"vars/notes.txt": "ignored",
+		"extra.vars":     `{"name": "extra"}`,
+	})
+
+	// 目录中的文件按文件名排序合并，额外文件可以用 @格式 指定格式
+	loader := NewDefaultVariableLoader("", "", "")
+	vars, err := loader.LoadVariables(filepath.Join(tempDir, "vars"), []string{filepath.Join(tempDir, "extra.vars") + "@json"})
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"name":    "extra",
+		"version": "1.0",
+		"TOKEN":   "secret",
+		"db":      map[string]interface{}{"host": "localhost", "port": 5432, "ssl": true},
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("LoadVariables() = %v, want %v", vars, want)
+	}
+}
+
+func TestGenerator_Variables(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "generator_variables_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/.gen_schema.yaml": "properties:\n  debug: {type: boolean, default: false}\n",
+		"variables/a.yaml":           "server:\n  host: localhost\n  port: 80\n",
+		"variables/b.toml":           "[server]\nport = 8080\n",
+	})
+	t.Setenv("GENVARS_SERVER__TLS", "true")
+	overrides, _ := variables.ParseSet("server.host=example.com")
+	cfg := &config.Config{
+		TemplateDir:       filepath.Join(tempDir, "templates"),
+		VariablesDir:      filepath.Join(tempDir, "variables"),
+		OutputDir:         filepath.Join(tempDir, "output"),
+		VariableEnvPrefix: "GENVARS_",
+	}
+
+	vars, provenance, err := NewGenerator().WithVariableOverrides(overrides).Variables(cfg)
+	if err != nil {
+		t.Fatalf("Variables() error = %v", err)
+	}
+	wantVars := map[string]interface{}{
+		"server": map[string]interface{}{"host": "example.com", "port": 8080, "tls": true},
+		"debug":  false,
+		// 未使用变量配置时保留变量为空
+		ProfileVariable:  "",
+		ProfilesVariable: []interface{}{},
+	}
+	if !reflect.DeepEqual(vars, wantVars) {
+		t.Errorf("Variables() = %v, want %v", vars, wantVars)
+	}
+
+	// 每个值记录最终生效的来源
+	wantOrigins := map[string]variables.Origin{
+		"server":      {File: filepath.Join(cfg.VariablesDir, "b.toml")},
+		"server.host": {File: "-set server.host"},
+		"server.port": {File: filepath.Join(cfg.VariablesDir, "b.toml")},
+		"server.tls":  {File: "环境变量 GENVARS_SERVER__TLS"},
+		"debug":       {File: "默认值 " + filepath.Join(cfg.TemplateDir, ".gen_schema.yaml")},
+		"__profile":   {File: "变量配置"},
+		"__profiles":  {File: "变量配置"},
+	}
+	if got := provenance.Origins(); !reflect.DeepEqual(got, wantOrigins) {
+		t.Errorf("Origins() = %v, want %v", got, wantOrigins)
+	}
+
+	// 变量加载器只记录变量文件中的来源
+	_, provenance, err = NewDefaultVariableLoader(cfg.TemplateDir, cfg.VariablesDir, cfg.OutputDir).LoadVariablesWithProvenance(cfg.VariablesDir, nil)
+	if err != nil {
+		t.Fatalf("LoadVariablesWithProvenance() error = %v", err)
+	}
+	if origin, ok := provenance.Lookup("server.host"); !ok || origin != (variables.Origin{File: filepath.Join(cfg.VariablesDir, "a.yaml"), Line: 2, Column: 3}) {
+		t.Errorf("Lookup(server.host) = %v, want a.yaml:2:3", origin)
+	}
+}
+
+func TestGenerateFiles_ComputedVariables(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "computed_variables_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/__binary__.txt.tpl": "{{ .module }} {{ .image }}",
+		"variables/variables.yaml":     "project:\n  name: demo\nimage: registry/${binary}:latest\ncomputed:\n  module: \"github.com/acme/{{ .project.name }}\"\n  binary: \"{{ .project.name | ucfirst }}Server\"\n",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+	}
+
+	// 计算后的值同时用于输出路径和模板内容，-set 覆盖的值参与计算
+	overrides, _ := variables.ParseSet("project.name=app")
+	recorder := &recordingContentGenerator{DefaultContentGenerator: NewDefaultContentGenerator()}
+	gen := NewGenerator().WithVariableOverrides(overrides).WithContentGenerator(recorder)
+	files, err := gen.GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	wantPath := filepath.Join(cfg.OutputDir, "AppServer.txt")
+	wantContent := "github.com/acme/app registry/AppServer:latest"
+	if len(files) != 1 || files[0].OutputPath != wantPath || files[0].Content != wantContent {
+		t.Fatalf("GenerateFiles() = %+v, want %s %q", files, wantPath, wantContent)
+	}
+
+	// 变量只计算一次，输出路径与模板内容使用同一份计算结果
+	if reflect.ValueOf(recorder.vars).Pointer() != reflect.ValueOf(gen.variables).Pointer() {
+		t.Error("output paths and template content use separately resolved variables")
+	}
+}
+
+// recordingContentGenerator 记录渲染模板时模板引擎中的变量
+type recordingContentGenerator struct {
+	*DefaultContentGenerator
+	vars map[string]interface{}
+}
+
+func (g *recordingContentGenerator) GenerateContent(templateFile TemplateFile, outputPath string, engine interface{}) (string, error) {
+	g.vars = engine.(*template.Engine).GetVariables()
+	return g.DefaultContentGenerator.GenerateContent(templateFile, outputPath, engine)
+}
@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/clh021/generator/internal/template"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// WhenFileName 声明生成条件的文件名，可以放在模板目录的任意子目录中
+// 文件内容为一个条件时，条件作用于所在目录中的所有模板；
+// 为 <glob模式>: <条件> 的映射时，模式相对于所在目录，条件只作用于匹配的模板
+const WhenFileName = ".gen_when"
+
+// whenRule 一条生成条件
+type whenRule struct {
+	file      string // 声明条件的 .gen_when 文件，相对于模板目录
+	pattern   string // 相对于模板目录的 doublestar 模式
+	condition string // 条件表达式，写法与 {{ if }} 中相同
+	ok        bool   // 条件的求值结果
+}
+
+// ConditionFilter 按 .gen_when 中的条件过滤模板
+// 条件在渲染前按变量求值，匹配的所有条件都成立时才生成模板
+type ConditionFilter struct {
+	rules []whenRule
+}
+
+// LoadConditionFilter 读取模板目录中的所有 .gen_when 文件，并用引擎中的变量对其中的条件求值
+// 没有 .gen_when 文件时返回的过滤器包含所有模板
+func LoadConditionFilter(templateDir string, engine *template.Engine) (*ConditionFilter, error) {
+	filter := &ConditionFilter{}
+	err := filepath.WalkDir(templateDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != WhenFileName {
+			return nil
+		}
+		rel, err := filepath.Rel(templateDir, p)
+		if err != nil {
+			return err
+		}
+		rules, err := parseWhenFile(p, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		filter.rules = append(filter.rules, rules...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range filter.rules {
+		rule := &filter.rules[i]
+		if rule.ok, err = engine.EvalCondition(rule.condition); err != nil {
+			return nil, errors.Wrapf(err, "%s 中的条件 %s", rule.file, rule.condition)
+		}
+	}
+	return filter, nil
+}
+
+// parseWhenFile 解析 .gen_when 文件，rel 为文件相对于模板目录的路径（使用 / 分隔）
+func parseWhenFile(p, rel string) ([]whenRule, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取条件文件失败: %s", p)
+	}
+	var content interface{}
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, errors.Wrapf(err, "解析条件文件失败: %s", p)
+	}
+
+	dir := path.Dir(rel)
+	join := func(pattern string) string {
+		if dir == "." {
+			return pattern
+		}
+		return dir + "/" + pattern
+	}
+
+	switch t := content.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		patterns := make([]string, 0, len(t))
+		for pattern := range t {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		rules := make([]whenRule, 0, len(t))
+		for _, pattern := range patterns {
+			condition, ok := whenCondition(t[pattern])
+			if !ok {
+				return nil, errors.Errorf("条件文件 %s 中 %s 的条件应为字符串或布尔值", p, pattern)
+			}
+			if !doublestar.ValidatePattern(join(pattern)) {
+				return nil, errors.Errorf("条件文件 %s 中的匹配模式无效: %s", p, pattern)
+			}
+			rules = append(rules, whenRule{file: rel, pattern: join(pattern), condition: condition})
+		}
+		return rules, nil
+	default:
+		condition, ok := whenCondition(content)
+		if !ok {
+			return nil, errors.Errorf("条件文件 %s 的内容应为一个条件，或 <glob模式>: <条件> 的映射", p)
+		}
+		return []whenRule{{file: rel, pattern: join("**"), condition: condition}}, nil
+	}
+}
+
+// whenCondition 将 .gen_when 中的值转换为条件表达式，布尔值表示总是或从不生成
+func whenCondition(value interface{}) (string, bool) {
+	switch t := value.(type) {
+	case string:
+		return t, t != ""
+	case bool:
+		return strconv.FormatBool(t), true
+	}
+	return "", false
+}
+
+// ShouldInclude 检查模板匹配的所有条件是否都成立，返回第一个不成立的条件作为排除原因
+func (f *ConditionFilter) ShouldInclude(path, relativePath string) (bool, string) {
+	slashPath := filepath.ToSlash(relativePath)
+	for _, rule := range f.rules {
+		if rule.ok {
+			continue
+		}
+		if ok, _ := doublestar.Match(rule.pattern, slashPath); ok {
+			return false, "条件不成立: " + rule.file + ": " + rule.condition
+		}
+	}
+	return true, ""
+}
+
+// filterTemplates 返回通过过滤器的模板，并记录被跳过的模板及原因
+func filterTemplates(templateFiles []TemplateFile, filter TemplateFilter) []TemplateFile {
+	included := make([]TemplateFile, 0, len(templateFiles))
+	for _, templateFile := range templateFiles {
+		if ok, reason := filter.ShouldInclude(templateFile.Path, templateFile.RelativePath); !ok {
+			log.Printf("跳过模板: %s (%s)", templateFile.RelativePath, reason)
+			continue
+		}
+		included = append(included, templateFile)
+	}
+	return included
+}
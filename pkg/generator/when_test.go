@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/clh021/generator/pkg/config"
+)
+
+func TestGenerateFiles_When(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "when_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"templates/main.go.tpl":              "main",
+		"templates/Dockerfile.tpl":           "docker",
+		"templates/.gen_when":                "Dockerfile.tpl: .features.docker\nnever.txt.tpl: false\n",
+		"templates/never.txt.tpl":            "never",
+		"templates/auth/.gen_when":           ".features.auth",
+		"templates/auth/login.go.tpl":        "login",
+		"templates/auth/oauth/google.go.tpl": "google",
+		"templates/api/.gen_when":            "'v2/**': and .features.auth (eq .api.version 2)",
+		"templates/api/v1/users.go.tpl":      "v1",
+		"templates/api/v2/users.go.tpl":      "v2",
+		"variables/variables.yaml":           "features: {auth: false, docker: true}\napi: {version: 2}\n",
+	})
+	cfg := &config.Config{
+		TemplateDir:  filepath.Join(tempDir, "templates"),
+		VariablesDir: filepath.Join(tempDir, "variables"),
+		OutputDir:    filepath.Join(tempDir, "output"),
+	}
+
+	generated, err := NewGenerator().GenerateFiles(cfg)
+	if err != nil {
+		t.Fatalf("GenerateFiles() error = %v", err)
+	}
+	var got []string
+	for _, file := range generated {
+		rel, _ := filepath.Rel(cfg.OutputDir, file.OutputPath)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	// 条件文件本身不是模板
+	want := []string{"Dockerfile", "api/v1/users.go", "main.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GenerateFiles() = %v, want %v", got, want)
+	}
+
+	// 条件引用不存在的变量时报错
+	writeTestFiles(t, tempDir, map[string]string{"templates/auth/.gen_when": ".features.sso"})
+	if _, err := NewGenerator().GenerateFiles(cfg); err == nil || !strings.Contains(err.Error(), "auth/.gen_when") {
+		t.Errorf("GenerateFiles() error = %v, want error for auth/.gen_when", err)
+	}
+
+	writeTestFiles(t, tempDir, map[string]string{"templates/auth/.gen_when": "[a, b]"})
+	if _, err := NewGenerator().GenerateFiles(cfg); err == nil {
+		t.Error("GenerateFiles() expected error for invalid .gen_when, got nil")
+	}
+}
+
+func TestConditionFilter_ShouldInclude(t *testing.T) {
+	filter := &ConditionFilter{rules: []whenRule{
+		{file: ".gen_when", pattern: "docs/**", condition: ".features.docs", ok: true},
+		{file: "auth/.gen_when", pattern: "auth/**", condition: ".features.auth", ok: false},
+	}}
+
+	tests := []struct {
+		relativePath string
+		want         bool
+		wantReason   string
+	}{
+		{"docs/index.md.tpl", true, ""},
+		{"main.go.tpl", true, ""},
+		{"auth/login.go.tpl", false, "条件不成立: auth/.gen_when: .features.auth"},
+		{"auth/oauth/google.go.tpl", false, "条件不成立: auth/.gen_when: .features.auth"},
+		{"authz/policy.go.tpl", true, ""},
+	}
+	for _, tt := range tests {
+		got, reason := filter.ShouldInclude(filepath.Join("/templates", tt.relativePath), tt.relativePath)
+		if got != tt.want || reason != tt.wantReason {
+			t.Errorf("ShouldInclude(%s) = %v, %q, want %v, %q", tt.relativePath, got, reason, tt.want, tt.wantReason)
+		}
+	}
+}
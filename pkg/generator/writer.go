@@ -0,0 +1,229 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/clh021/generator/pkg/config"
+	"github.com/pkg/errors"
+)
+
+// WritePolicy 定义目标文件已存在时的写入策略
+type WritePolicy string
+
+const (
+	// WritePolicyOverwrite 总是覆盖目标文件
+	WritePolicyOverwrite WritePolicy = "overwrite"
+	// WritePolicySkipIfExists 目标文件存在时跳过
+	WritePolicySkipIfExists WritePolicy = "skip-if-exists"
+	// WritePolicyFailIfExists 目标文件存在时报错
+	WritePolicyFailIfExists WritePolicy = "fail-if-exists"
+	// WritePolicyWriteIfChanged 内容不同时才写入，内容相同时不修改文件（保留修改时间）
+	WritePolicyWriteIfChanged WritePolicy = "write-if-changed"
+	// WritePolicyBackup 覆盖前将现有文件备份为 <文件名>.bak
+	WritePolicyBackup WritePolicy = "backup"
+	// WritePolicyBackupTimestamp 覆盖前将现有文件备份为 <文件名>.<时间戳>.bak
+	WritePolicyBackupTimestamp WritePolicy = "backup-timestamp"
+	// WritePolicyMerge 以上次生成的内容为共同祖先，将新内容与现有文件做三方合并，无法自动合并的部分写入冲突标记
+	WritePolicyMerge WritePolicy = "merge"
+)
+
+// ErrOutputExists 在 fail-if-exists 策略下目标文件已存在时返回
+var ErrOutputExists = errors.New("目标文件已存在")
+
+// ParseWritePolicy 解析写入策略，空字符串表示默认的 overwrite
+func ParseWritePolicy(s string) (WritePolicy, error) {
+	switch policy := WritePolicy(s); policy {
+	case "":
+		return WritePolicyOverwrite, nil
+	case WritePolicyOverwrite, WritePolicySkipIfExists, WritePolicyFailIfExists,
+		WritePolicyWriteIfChanged, WritePolicyBackup, WritePolicyBackupTimestamp, WritePolicyMerge:
+		return policy, nil
+	default:
+		return "", errors.Errorf("未知的写入策略: %s", s)
+	}
+}
+
+// WriteAction 表示写入文件时实际执行的操作
+type WriteAction string
+
+const (
+	// WriteActionCreated 创建了新文件
+	WriteActionCreated WriteAction = "created"
+	// WriteActionOverwritten 覆盖了现有文件
+	WriteActionOverwritten WriteAction = "overwritten"
+	// WriteActionUnchanged 内容相同，未修改文件
+	WriteActionUnchanged WriteAction = "unchanged"
+	// WriteActionSkipped 按策略跳过了现有文件
+	WriteActionSkipped WriteAction = "skipped"
+	// WriteActionDropped 内容只包含空白，未写入
+	WriteActionDropped WriteAction = "dropped"
+	// WriteActionRemoved 内容只包含空白，删除了上次生成的文件
+	WriteActionRemoved WriteAction = "removed"
+	// WriteActionKeptModified 内容只包含空白，但上次生成的文件在生成后被修改过，未删除
+	WriteActionKeptModified WriteAction = "kept-modified"
+)
+
+// WriteResult 表示单个文件的写入结果
+type WriteResult struct {
+	// 目标文件路径
+	OutputPath string
+	// 实际执行的操作
+	Action WriteAction
+	// 备份文件路径，未备份时为空
+	BackupPath string
+	// 现有文件中在新内容里已不存在的保留区域
+	OrphanedRegions []KeptRegion
+	// 操作完成后目标文件内容的哈希
+	Hash string
+	// 三方合并时写入冲突标记的冲突数量
+	Conflicts int
+}
+
+// OutputWriter 定义输出写入器接口
+type OutputWriter interface {
+	// WriteFile 将生成的文件写入目标位置
+	WriteFile(file GeneratedFile) (WriteResult, error)
+}
+
+// FileSystemWriter 默认的输出写入器实现，将文件写入本地文件系统
+type FileSystemWriter struct {
+	// 文件未指定写入策略时使用的策略
+	DefaultPolicy WritePolicy
+	// 新建文件的权限
+	FileMode os.FileMode
+	// 新建目录的权限
+	DirMode os.FileMode
+	// 获取当前时间，用于生成带时间戳的备份文件名
+	Now func() time.Time
+	// 为 true 时，现有文件中的保留区域在新内容中不存在只记录在结果中，否则返回 OrphanedRegionsError
+	AllowOrphanedRegions bool
+}
+
+// NewFileSystemWriter 创建使用指定默认策略的文件系统写入器
+func NewFileSystemWriter(policy WritePolicy) *FileSystemWriter {
+	return &FileSystemWriter{
+		DefaultPolicy: policy,
+		FileMode:      0644,
+		DirMode:       0755,
+		Now:           time.Now,
+	}
+}
+
+// WriteFile 按写入策略将生成的文件写入文件系统
+func (w *FileSystemWriter) WriteFile(file GeneratedFile) (WriteResult, error) {
+	result := WriteResult{OutputPath: file.OutputPath}
+
+	policy := file.WritePolicy
+	if policy == "" {
+		policy = w.DefaultPolicy
+	}
+	policy, err := ParseWritePolicy(string(policy))
+	if err != nil {
+		return result, err
+	}
+
+	status, existing, err := file.Status()
+	if err != nil {
+		return result, err
+	}
+
+	if status != FileStatusNew {
+		switch policy {
+		case WritePolicySkipIfExists:
+			result.Action = WriteActionSkipped
+			result.Hash = ContentHash(existing)
+			return result, nil
+		case WritePolicyFailIfExists:
+			return result, errors.Wrapf(ErrOutputExists, "%s", file.OutputPath)
+		}
+
+		// 合并现有文件的修改，并将其中的保留区域注入新内容
+		file, result.OrphanedRegions, result.Conflicts, err = file.MergeExisting(existing, policy)
+		if err != nil {
+			return result, err
+		}
+		if len(result.OrphanedRegions) > 0 && !w.AllowOrphanedRegions {
+			return result, &OrphanedRegionsError{Path: file.OutputPath, Regions: result.OrphanedRegions}
+		}
+		if file.Content == existing {
+			status = FileStatusUnchanged
+		} else {
+			status = FileStatusChanged
+		}
+
+		switch policy {
+		case WritePolicyWriteIfChanged, WritePolicyBackup, WritePolicyBackupTimestamp, WritePolicyMerge:
+			// 内容相同时不修改文件，也无需备份
+			if status == FileStatusUnchanged {
+				result.Action = WriteActionUnchanged
+				result.Hash = ContentHash(existing)
+				return result, nil
+			}
+		}
+
+		if policy == WritePolicyBackup || policy == WritePolicyBackupTimestamp {
+			result.BackupPath = w.backupPath(file.OutputPath, policy)
+			if err := os.Rename(file.OutputPath, result.BackupPath); err != nil {
+				return result, errors.Wrapf(err, "备份文件失败: %s", file.OutputPath)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file.OutputPath), w.DirMode); err != nil {
+		return result, errors.Wrapf(err, "创建输出目录失败: %s", filepath.Dir(file.OutputPath))
+	}
+	if err := os.WriteFile(file.OutputPath, []byte(file.Content), w.FileMode); err != nil {
+		return result, errors.Wrapf(err, "写入文件失败: %s", file.OutputPath)
+	}
+
+	result.Hash = ContentHash(file.Content)
+	if status == FileStatusNew {
+		result.Action = WriteActionCreated
+	} else {
+		result.Action = WriteActionOverwritten
+	}
+	return result, nil
+}
+
+// backupPath 返回现有文件的备份路径
+func (w *FileSystemWriter) backupPath(path string, policy WritePolicy) string {
+	if policy == WritePolicyBackupTimestamp {
+		return path + "." + w.Now().Format("20060102-150405") + ".bak"
+	}
+	return path + ".bak"
+}
+
+// matchWritePolicy 返回第一个匹配模板相对路径的规则中的策略，无匹配时返回空字符串
+func matchWritePolicy(rules []config.WritePolicyRule, relativePath string) WritePolicy {
+	slashPath := filepath.ToSlash(relativePath)
+	for _, rule := range rules {
+		if ok, _ := doublestar.Match(rule.Pattern, slashPath); ok {
+			return WritePolicy(rule.Policy)
+		}
+	}
+	return ""
+}
+
+// effectivePolicy 返回文件实际使用的写入策略，文件未指定时使用 defaultPolicy
+func effectivePolicy(file GeneratedFile, defaultPolicy WritePolicy) WritePolicy {
+	if file.WritePolicy != "" {
+		return file.WritePolicy
+	}
+	return defaultPolicy
+}
+
+// validateWritePolicyRules 校验按模板指定的写入策略规则
+func validateWritePolicyRules(rules []config.WritePolicyRule) error {
+	for _, rule := range rules {
+		if !doublestar.ValidatePattern(rule.Pattern) {
+			return errors.Errorf("无效的匹配模式: %s", rule.Pattern)
+		}
+		if _, err := ParseWritePolicy(rule.Policy); err != nil {
+			return errors.Wrapf(err, "写入策略规则 %s", rule.Pattern)
+		}
+	}
+	return nil
+}
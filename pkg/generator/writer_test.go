@@ -0,0 +1,171 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/clh021/generator/pkg/config"
+	"github.com/pkg/errors"
+)
+
+func TestParseWritePolicy(t *testing.T) {
+	policy, err := ParseWritePolicy("")
+	if err != nil || policy != WritePolicyOverwrite {
+		t.Errorf("ParseWritePolicy(\"\") = %v, %v, want %v", policy, err, WritePolicyOverwrite)
+	}
+
+	policy, err = ParseWritePolicy("write-if-changed")
+	if err != nil || policy != WritePolicyWriteIfChanged {
+		t.Errorf("ParseWritePolicy(write-if-changed) = %v, %v", policy, err)
+	}
+
+	if _, err := ParseWritePolicy("unknown"); err == nil {
+		t.Error("ParseWritePolicy(unknown) expected error, got nil")
+	}
+}
+
+func TestFileSystemWriter_WriteFile(t *testing.T) {
+	fixedTime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	oldTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		existing    *string
+		content     string
+		policy      WritePolicy
+		wantAction  WriteAction
+		wantContent string
+		wantBackup  string
+		wantErr     error
+		wantOldTime bool
+	}{
+		{"create", nil, "new", WritePolicyFailIfExists, WriteActionCreated, "new", "", nil, false},
+		{"overwrite", strPtr("old"), "new", WritePolicyOverwrite, WriteActionOverwritten, "new", "", nil, false},
+		{"skip if exists", strPtr("old"), "new", WritePolicySkipIfExists, WriteActionSkipped, "old", "", nil, true},
+		{"fail if exists", strPtr("old"), "new", WritePolicyFailIfExists, "", "old", "", ErrOutputExists, true},
+		{"write if changed - same", strPtr("same"), "same", WritePolicyWriteIfChanged, WriteActionUnchanged, "same", "", nil, true},
+		{"write if changed - different", strPtr("old"), "new", WritePolicyWriteIfChanged, WriteActionOverwritten, "new", "", nil, false},
+		{"backup", strPtr("old"), "new", WritePolicyBackup, WriteActionOverwritten, "new", "out.txt.bak", nil, false},
+		{"backup timestamp", strPtr("old"), "new", WritePolicyBackupTimestamp, WriteActionOverwritten, "new", "out.txt.20250102-030405.bak", nil, false},
+		{"backup - same", strPtr("same"), "same", WritePolicyBackup, WriteActionUnchanged, "same", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir, err := os.MkdirTemp("", "writer_test")
+			if err != nil {
+				t.Fatalf("Failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(tempDir)
+
+			outputPath := filepath.Join(tempDir, "out.txt")
+			if tt.existing != nil {
+				if err := os.WriteFile(outputPath, []byte(*tt.existing), 0644); err != nil {
+					t.Fatalf("Failed to write file: %v", err)
+				}
+				if err := os.Chtimes(outputPath, oldTime, oldTime); err != nil {
+					t.Fatalf("Failed to set file time: %v", err)
+				}
+			}
+
+			writer := NewFileSystemWriter(WritePolicyOverwrite)
+			writer.Now = func() time.Time { return fixedTime }
+
+			result, err := writer.WriteFile(GeneratedFile{OutputPath: outputPath, Content: tt.content, WritePolicy: tt.policy})
+			if tt.wantErr != nil {
+				if errors.Cause(err) != tt.wantErr {
+					t.Fatalf("WriteFile() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			if result.Action != tt.wantAction {
+				t.Errorf("WriteFile() action = %v, want %v", result.Action, tt.wantAction)
+			}
+
+			data, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			if string(data) != tt.wantContent {
+				t.Errorf("output content = %q, want %q", string(data), tt.wantContent)
+			}
+
+			if tt.wantBackup != "" {
+				wantBackupPath := filepath.Join(tempDir, tt.wantBackup)
+				if result.BackupPath != wantBackupPath {
+					t.Errorf("WriteFile() backup = %v, want %v", result.BackupPath, wantBackupPath)
+				}
+				backup, err := os.ReadFile(wantBackupPath)
+				if err != nil || string(backup) != *tt.existing {
+					t.Errorf("backup content = %q, %v, want %q", string(backup), err, *tt.existing)
+				}
+			} else if result.BackupPath != "" {
+				t.Errorf("WriteFile() unexpected backup %v", result.BackupPath)
+			}
+
+			info, err := os.Stat(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to stat output: %v", err)
+			}
+			if tt.wantOldTime != info.ModTime().Equal(oldTime) {
+				t.Errorf("output mtime = %v, preserved want %v", info.ModTime(), tt.wantOldTime)
+			}
+		})
+	}
+}
+
+func TestFileSystemWriter_CreatesDirectories(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "writer_dir_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	outputPath := filepath.Join(tempDir, "a", "b", "c.txt")
+	result, err := NewFileSystemWriter("").WriteFile(GeneratedFile{OutputPath: outputPath, Content: "c"})
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if result.Action != WriteActionCreated {
+		t.Errorf("WriteFile() action = %v, want %v", result.Action, WriteActionCreated)
+	}
+	if !fileExists(outputPath) {
+		t.Errorf("Expected %s to be created", outputPath)
+	}
+}
+
+func TestMatchWritePolicy(t *testing.T) {
+	rules := []config.WritePolicyRule{
+		{Pattern: "handlers/**", Policy: "skip-if-exists"},
+		{Pattern: "**/*.go.tpl", Policy: "write-if-changed"},
+	}
+
+	tests := []struct {
+		path string
+		want WritePolicy
+	}{
+		{"handlers/user.go.tpl", WritePolicySkipIfExists},
+		{"main.go.tpl", WritePolicyWriteIfChanged},
+		{"README.md.tpl", ""},
+	}
+	for _, tt := range tests {
+		if got := matchWritePolicy(rules, tt.path); got != tt.want {
+			t.Errorf("matchWritePolicy(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if err := validateWritePolicyRules(rules); err != nil {
+		t.Errorf("validateWritePolicyRules() error = %v", err)
+	}
+	if err := validateWritePolicyRules([]config.WritePolicyRule{{Pattern: "*", Policy: "bad"}}); err == nil {
+		t.Error("validateWritePolicyRules() expected error for unknown policy, got nil")
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
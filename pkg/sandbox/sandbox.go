@@ -0,0 +1,107 @@
+// Package sandbox 将文件访问限制在允许的目录中
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Error 访问允许的目录之外的路径时返回的错误
+type Error struct {
+	// 发起访问的操作，如模板函数 file、include
+	Op string
+	// 请求的路径
+	Path string
+	// 解析符号链接后的路径
+	Resolved string
+	// 允许访问的目录
+	Roots []string
+}
+
+func (e *Error) Error() string {
+	path := e.Path
+	if e.Resolved != "" && e.Resolved != e.Path {
+		path = fmt.Sprintf("%s（实际指向 %s）", e.Path, e.Resolved)
+	}
+	return fmt.Sprintf("%s: 禁止访问允许的目录之外的路径 %s，允许的目录: %s", e.Op, path, strings.Join(e.Roots, ", "))
+}
+
+// Sandbox 允许访问的目录列表
+// 检查路径前先解析符号链接，因此目录中指向外部的符号链接同样被拒绝
+type Sandbox struct {
+	roots []string
+}
+
+// New 创建只允许访问 roots 及其子目录的沙箱，相对路径相对于当前目录，空字符串会被忽略
+func New(roots ...string) (*Sandbox, error) {
+	s := &Sandbox{}
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		resolved, err := Resolve(root)
+		if err != nil {
+			return nil, errors.Wrapf(err, "解析沙箱目录 %s 失败", root)
+		}
+		s.roots = append(s.roots, resolved)
+	}
+	return s, nil
+}
+
+// Roots 返回解析后的允许访问的目录
+func (s *Sandbox) Roots() []string {
+	return s.roots
+}
+
+// Check 检查 path 是否位于允许的目录中，返回解析符号链接后的路径
+// 不允许访问时返回 *Error，op 为错误中记录的操作名称
+func (s *Sandbox) Check(op, path string) (string, error) {
+	resolved, err := Resolve(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "%s: 解析路径 %s 失败", op, path)
+	}
+	for _, root := range s.roots {
+		if Within(root, resolved) {
+			return resolved, nil
+		}
+	}
+	return "", &Error{Op: op, Path: path, Resolved: resolved, Roots: s.roots}
+}
+
+// Resolve 返回路径的绝对路径并解析其中的符号链接
+// 路径不存在时解析其存在的上级目录，再拼接剩余部分
+func Resolve(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return resolved, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	parent := filepath.Dir(abs)
+	if parent == abs {
+		return abs, nil
+	}
+	resolvedParent, err := Resolve(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolvedParent, filepath.Base(abs)), nil
+}
+
+// Within 判断 path 是否为 root 或位于 root 之中，两者都应是清理后的绝对路径
+func Within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
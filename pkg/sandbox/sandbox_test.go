@@ -0,0 +1,75 @@
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSandbox_Check(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "sandbox_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	// 临时目录本身可能位于符号链接之下（如 macOS 的 /tmp）
+	if tempDir, err = filepath.EvalSymlinks(tempDir); err != nil {
+		t.Fatalf("Failed to resolve temp dir: %v", err)
+	}
+
+	root := filepath.Join(tempDir, "root")
+	for _, dir := range []string{filepath.Join(root, "sub"), filepath.Join(tempDir, "rootless"), filepath.Join(tempDir, "outside")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(tempDir, "outside"), filepath.Join(root, "escape")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "sub"), filepath.Join(tempDir, "inside")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	s, err := New(root, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		allowed bool
+	}{
+		{name: "root", path: root, want: root, allowed: true},
+		{name: "missing file in root", path: filepath.Join(root, "sub", "a.txt"), want: filepath.Join(root, "sub", "a.txt"), allowed: true},
+		{name: "symlink into root", path: filepath.Join(tempDir, "inside", "a.txt"), want: filepath.Join(root, "sub", "a.txt"), allowed: true},
+		{name: "parent traversal", path: filepath.Join(root, "..", "outside", "secret")},
+		{name: "sibling with common prefix", path: filepath.Join(tempDir, "rootless", "a.txt")},
+		{name: "symlink out of root", path: filepath.Join(root, "escape", "secret")},
+		{name: "absolute path", path: "/etc/passwd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Check("file", tt.path)
+			if !tt.allowed {
+				var sandboxErr *Error
+				if !errors.As(err, &sandboxErr) {
+					t.Fatalf("Check() error = %v, want *Error", err)
+				}
+				if sandboxErr.Op != "file" || sandboxErr.Path != tt.path {
+					t.Errorf("Check() error = %+v", sandboxErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,9 +3,12 @@ package utils
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
+
+	"golang.org/x/term"
 )
 
 // DisplayFiles 显示文件列表
@@ -76,3 +79,57 @@ func GetUserSelection(files []VarFile, prompt string) ([]string, error) {
 	
 	return selectedPaths, nil
 }
+
+// IsTerminal 判断文件是否为交互式终端
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// PromptInput 输出提示并读取一行输入，输入为空时返回 defaultValue
+func PromptInput(in *bufio.Reader, out io.Writer, prompt, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Fprintf(out, "%s [%s]: ", prompt, defaultValue)
+	} else {
+		fmt.Fprintf(out, "%s: ", prompt)
+	}
+
+	input, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || input == "") {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return defaultValue, nil
+	}
+	return input, nil
+}
+
+// PromptChoice 显示编号的选项列表并读取选择，可以输入编号或选项本身，输入为空时返回 defaultValue
+func PromptChoice(in *bufio.Reader, out io.Writer, prompt string, choices []string, defaultValue string) (string, error) {
+	fmt.Fprintf(out, "%s:\n", prompt)
+	for i, choice := range choices {
+		fmt.Fprintf(out, "[%d] %s\n", i+1, choice)
+	}
+
+	input, err := PromptInput(in, out, fmt.Sprintf("Choose from 1-%d", len(choices)), defaultValue)
+	if err != nil {
+		return "", err
+	}
+	if input == "" {
+		return "", nil
+	}
+	for _, choice := range choices {
+		if input == choice {
+			return choice, nil
+		}
+	}
+	index, err := strconv.Atoi(input)
+	if err != nil {
+		return "", fmt.Errorf("invalid selection: %s", input)
+	}
+	if index < 1 || index > len(choices) {
+		return "", fmt.Errorf("invalid number: %d, valid range: 1-%d", index, len(choices))
+	}
+	return choices[index-1], nil
+}
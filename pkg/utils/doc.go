@@ -13,6 +13,7 @@
 // Command Line Interface Utilities (cli_ui.go):
 //   - Displaying file lists to users
 //   - Getting user selections from the command line
+//   - Prompting for single values and choices
 //
 // These utilities are designed to be used with the generator package
 // but can also be used independently in other applications.
@@ -1,10 +1,12 @@
 package utils_test
 
 import (
+	"bufio"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/clh021/generator/pkg/utils"
 )
@@ -99,3 +101,22 @@ func ExampleDisplayFiles() {
 	// [2] file2 (/path/to/file2.yml)
 	// ---------------------------
 }
+
+// This example demonstrates how to prompt for one of several choices.
+func ExamplePromptChoice() {
+	// Simulate the user typing "2"
+	in := bufio.NewReader(strings.NewReader("2\n"))
+
+	choice, err := utils.PromptChoice(in, os.Stdout, "Project type", []string{"service", "library"}, "service")
+	if err != nil {
+		log.Fatalf("Failed to read choice: %v", err)
+	}
+	// The prompt is not followed by a newline because the user's input ends the line
+	fmt.Println(choice)
+
+	// Output:
+	// Project type:
+	// [1] service
+	// [2] library
+	// Choose from 1-2 [service]: library
+}
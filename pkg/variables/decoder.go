@@ -0,0 +1,190 @@
+package variables
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// VariableDecoder 将变量文件的内容解码为变量映射
+type VariableDecoder interface {
+	Decode(data []byte) (map[string]interface{}, error)
+}
+
+// DecoderFunc 将普通函数适配为 VariableDecoder
+type DecoderFunc func(data []byte) (map[string]interface{}, error)
+
+// Decode 实现 VariableDecoder 接口
+func (f DecoderFunc) Decode(data []byte) (map[string]interface{}, error) {
+	return f(data)
+}
+
+// FormatSeparator 变量文件路径后用于指定格式的分隔符，例如 vars.txt@toml
+const FormatSeparator = "@"
+
+var (
+	decodersMu sync.RWMutex
+	// 格式名 -> 解码器
+	decoders = make(map[string]VariableDecoder)
+	// 扩展名（不含点） -> 格式名
+	extensions = make(map[string]string)
+)
+
+func init() {
+	RegisterDecoder("yaml", DecoderFunc(decodeYAML), "yaml", "yml")
+	RegisterDecoder("json", DecoderFunc(decodeJSON), "json")
+	RegisterDecoder("toml", DecoderFunc(decodeTOML), "toml")
+	RegisterDecoder("env", DecoderFunc(decodeEnv), "env")
+}
+
+// RegisterDecoder 注册变量文件格式，exts 为使用该格式的文件扩展名（不含点）
+// 重复注册同名格式或扩展名时覆盖之前的注册
+func RegisterDecoder(format string, decoder VariableDecoder, exts ...string) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[format] = decoder
+	for _, ext := range exts {
+		extensions[strings.ToLower(strings.TrimPrefix(ext, "."))] = format
+	}
+}
+
+// LookupDecoder 返回格式名对应的解码器
+func LookupDecoder(format string) (VariableDecoder, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	decoder, ok := decoders[format]
+	return decoder, ok
+}
+
+// Extensions 返回已注册的变量文件扩展名（不含点），按字母排序
+func Extensions() []string {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	result := make([]string, 0, len(extensions))
+	for ext := range extensions {
+		result = append(result, ext)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// SplitFormat 拆分变量文件参数中的路径和格式，例如 vars.txt@toml
+// 没有指定格式，或 @ 之后不是已注册的格式名时，整个参数都是路径
+func SplitFormat(spec string) (path, format string) {
+	i := strings.LastIndex(spec, FormatSeparator)
+	if i <= 0 {
+		return spec, ""
+	}
+	if _, ok := LookupDecoder(spec[i+1:]); !ok {
+		return spec, ""
+	}
+	return spec[:i], spec[i+1:]
+}
+
+// FormatOf 返回变量文件参数的格式：显式指定的格式优先，否则按扩展名判断
+// 无法识别时返回空字符串
+func FormatOf(spec string) string {
+	path, format := SplitFormat(spec)
+	if format != "" {
+		return format
+	}
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	return extensions[strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))]
+}
+
+// DecodeFile 读取并解码变量文件，spec 为文件路径，可以带 @格式 后缀
+func DecodeFile(spec string) (map[string]interface{}, error) {
+	path, _ := SplitFormat(spec)
+	format := FormatOf(spec)
+	decoder, ok := LookupDecoder(format)
+	if !ok {
+		return nil, errors.Errorf("无法识别变量文件 %s 的格式，可以使用 %s<格式> 后缀指定", path, FormatSeparator)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取变量文件 %s 失败", path)
+	}
+	vars, err := decoder.Decode(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "解析 %s 变量文件 %s 失败", format, path)
+	}
+	return vars, nil
+}
+
+func decodeYAML(data []byte) (map[string]interface{}, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var vars map[string]interface{}
+	if len(doc.Content) == 0 {
+		return vars, nil
+	}
+	// !secret 只标记机密，不影响值的类型
+	stripSecretTags(&doc)
+	if err := doc.Decode(&vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func decodeJSON(data []byte) (map[string]interface{}, error) {
+	var vars map[string]interface{}
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, err
+	}
+	return NormalizeJSON(vars).(map[string]interface{}), nil
+}
+
+func decodeTOML(data []byte) (map[string]interface{}, error) {
+	var vars map[string]interface{}
+	if err := toml.Unmarshal(data, &vars); err != nil {
+		return nil, err
+	}
+	return normalizeTOML(vars).(map[string]interface{}), nil
+}
+
+func decodeEnv(data []byte) (map[string]interface{}, error) {
+	env, err := godotenv.UnmarshalBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]interface{}, len(env))
+	for k, v := range env {
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// normalizeTOML 将 TOML 解码结果转换为与 YAML 变量文件一致的类型：整数为 int，表数组为 []interface{}
+func normalizeTOML(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			t[k] = normalizeTOML(item)
+		}
+	case []interface{}:
+		for i, item := range t {
+			t[i] = normalizeTOML(item)
+		}
+	case []map[string]interface{}:
+		list := make([]interface{}, len(t))
+		for i, item := range t {
+			list[i] = normalizeTOML(item)
+		}
+		return list
+	case int64:
+		return int(t)
+	}
+	return v
+}
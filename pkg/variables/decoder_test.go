@@ -0,0 +1,172 @@
+package variables
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeFile(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "decoder_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	want := map[string]interface{}{
+		"name":    "app",
+		"port":    8080,
+		"ratio":   0.5,
+		"enabled": true,
+		"tags":    []interface{}{"a", "b"},
+		"db":      map[string]interface{}{"host": "localhost"},
+		"routes": []interface{}{
+			map[string]interface{}{"name": "users"},
+			map[string]interface{}{"name": "posts"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		spec    string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:    "yaml",
+			file:    "vars.yaml",
+			content: "name: app\nport: 8080\nratio: 0.5\nenabled: true\ntags: [a, b]\ndb:\n  host: localhost\nroutes:\n  - name: users\n  - name: posts\n",
+			want:    want,
+		},
+		{
+			name:    "json",
+			file:    "vars.json",
+			content: `{"name":"app","port":8080,"ratio":0.5,"enabled":true,"tags":["a","b"],"db":{"host":"localhost"},"routes":[{"name":"users"},{"name":"posts"}]}`,
+			want:    want,
+		},
+		{
+			name:    "toml",
+			file:    "vars.toml",
+			content: "name = \"app\"\nport = 8080\nratio = 0.5\nenabled = true\ntags = [\"a\", \"b\"]\n\n[db]\nhost = \"localhost\"\n\n[[routes]]\nname = \"users\"\n\n[[routes]]\nname = \"posts\"\n",
+			want:    want,
+		},
+		{
+			name:    "env values are strings",
+			file:    "prod.env",
+			content: "# comment\nNAME=app\nPORT=8080\nexport TITLE=\"Hello World\"\n",
+			want:    map[string]interface{}{"NAME": "app", "PORT": "8080", "TITLE": "Hello World"},
+		},
+		{
+			name:    "format suffix overrides extension",
+			file:    "vars.txt",
+			content: "port = 1\n",
+			spec:    "vars.txt@toml",
+			want:    map[string]interface{}{"port": 1},
+		},
+		{
+			name:    "unknown extension",
+			file:    "vars.txt",
+			content: "port: 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid content",
+			file:    "bad.json",
+			content: "{",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			spec := path
+			if tt.spec != "" {
+				spec = filepath.Join(tempDir, tt.spec)
+			}
+
+			got, err := DecodeFile(spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeFile(%s) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeFile() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitFormat(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantPath   string
+		wantFormat string
+	}{
+		{"vars.yaml", "vars.yaml", ""},
+		{"vars.txt@toml", "vars.txt", "toml"},
+		{"dir/user@example.com.yaml", "dir/user@example.com.yaml", ""},
+		{"vars@unknown", "vars@unknown", ""},
+		{"@json", "@json", ""},
+	}
+
+	for _, tt := range tests {
+		path, format := SplitFormat(tt.spec)
+		if path != tt.wantPath || format != tt.wantFormat {
+			t.Errorf("SplitFormat(%q) = %q, %q, want %q, %q", tt.spec, path, format, tt.wantPath, tt.wantFormat)
+		}
+	}
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	// 注册自定义格式：每行一个 key:value
+	RegisterDecoder("kv", DecoderFunc(func(data []byte) (map[string]interface{}, error) {
+		vars := make(map[string]interface{})
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			k, v, _ := strings.Cut(line, ":")
+			vars[k] = v
+		}
+		return vars, nil
+	}), ".kv")
+	defer func() {
+		decodersMu.Lock()
+		delete(decoders, "kv")
+		delete(extensions, "kv")
+		decodersMu.Unlock()
+	}()
+
+	if FormatOf("a.KV") != "kv" {
+		t.Errorf("FormatOf(a.KV) = %q, want kv", FormatOf("a.KV"))
+	}
+	found := false
+	for _, ext := range Extensions() {
+		found = found || ext == "kv"
+	}
+	if !found {
+		t.Errorf("Extensions() = %v, want kv included", Extensions())
+	}
+
+	tempDir, err := os.MkdirTemp("", "decoder_register_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, "vars.kv")
+	if err := os.WriteFile(path, []byte("name:app\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	got, err := DecodeFile(path)
+	if err != nil {
+		t.Fatalf("DecodeFile() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]interface{}{"name": "app"}) {
+		t.Errorf("DecodeFile() = %v", got)
+	}
+}
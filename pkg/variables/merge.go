@@ -0,0 +1,316 @@
+// Package variables 提供模板变量的合并等处理
+package variables
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DeleteSentinel 作为值使用时，从合并结果中删除对应的键
+//
+//	database:
+//	  options: ~delete
+const DeleteSentinel = "~delete"
+
+// ListMergeMode 列表的合并方式
+type ListMergeMode string
+
+const (
+	// ListReplace 后面的列表替换前面的列表
+	ListReplace ListMergeMode = "replace"
+	// ListAppend 将后面的列表追加到前面的列表之后
+	ListAppend ListMergeMode = "append"
+	// ListMergeByKey 按指定字段匹配列表中的映射项，匹配的项深度合并，其余追加
+	// 带有 ~delete: true 的项会删除匹配的列表项
+	ListMergeByKey ListMergeMode = "merge-by-key"
+)
+
+// DefaultMergeKey merge-by-key 未指定字段时使用的字段名
+const DefaultMergeKey = "name"
+
+// ListStrategy 列表合并策略
+type ListStrategy struct {
+	Mode ListMergeMode
+	// merge-by-key 时用于匹配列表项的字段
+	Key string
+}
+
+func (s ListStrategy) String() string {
+	if s.Mode == ListMergeByKey {
+		return string(s.Mode) + ":" + s.Key
+	}
+	return string(s.Mode)
+}
+
+// ParseListStrategy 解析列表合并策略: replace, append, merge-by-key 或 merge-by-key:<字段>
+// 空字符串表示 replace
+func ParseListStrategy(s string) (ListStrategy, error) {
+	mode, key, hasKey := strings.Cut(strings.TrimSpace(s), ":")
+	switch ListMergeMode(mode) {
+	case "", ListReplace:
+		if !hasKey {
+			return ListStrategy{Mode: ListReplace}, nil
+		}
+	case ListAppend:
+		if !hasKey {
+			return ListStrategy{Mode: ListAppend}, nil
+		}
+	case ListMergeByKey:
+		if !hasKey {
+			key = DefaultMergeKey
+		}
+		if key != "" {
+			return ListStrategy{Mode: ListMergeByKey, Key: key}, nil
+		}
+	}
+	return ListStrategy{}, errors.Errorf("未知的列表合并策略: %s", s)
+}
+
+// MergeOptions 控制变量合并的行为
+type MergeOptions struct {
+	// 默认的列表合并策略
+	Lists ListStrategy
+	// 按变量路径（点分隔，如 server.routes）指定的列表合并策略
+	ListPaths map[string]ListStrategy
+}
+
+// NewMergeOptions 根据策略字符串创建合并选项
+func NewMergeOptions(lists string, listPaths map[string]string) (MergeOptions, error) {
+	var opts MergeOptions
+	var err error
+	if opts.Lists, err = ParseListStrategy(lists); err != nil {
+		return opts, err
+	}
+	for path, s := range listPaths {
+		strategy, err := ParseListStrategy(s)
+		if err != nil {
+			return opts, errors.Wrapf(err, "变量路径 %s", path)
+		}
+		if opts.ListPaths == nil {
+			opts.ListPaths = make(map[string]ListStrategy)
+		}
+		opts.ListPaths[path] = strategy
+	}
+	return opts, nil
+}
+
+// listStrategy 返回指定路径上列表的合并策略
+func (o MergeOptions) listStrategy(path string) ListStrategy {
+	if strategy, ok := o.ListPaths[path]; ok {
+		return strategy
+	}
+	if o.Lists.Mode == "" {
+		return ListStrategy{Mode: ListReplace}
+	}
+	return o.Lists
+}
+
+// Merge 将 src 深度合并到 dst 中并返回 dst（dst 为 nil 时创建新的映射）
+//   - 映射逐键递归合并
+//   - 列表按 opts 中的策略合并
+//   - 其他类型的值由 src 覆盖
+//   - 值为 ~delete 的键从结果中删除
+//
+// src 中的映射和列表会被复制，合并后修改 dst 不会影响 src
+func Merge(dst, src map[string]interface{}, opts MergeOptions) map[string]interface{} {
+	return MergeWithProvenance(dst, src, nil, nil, opts)
+}
+
+// MergeWithProvenance 与 Merge 相同，同时将 src 中值的来源合并到 dstProvenance 中
+// dstProvenance 为 nil 时不记录来源；srcProvenance 为 nil 时 src 中的值来源未知
+func MergeWithProvenance(dst, src map[string]interface{}, dstProvenance, srcProvenance *Provenance, opts MergeOptions) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	var dstNode, srcNode *originNode
+	if dstProvenance != nil {
+		dstNode, srcNode = dstProvenance.root, &originNode{}
+		if srcProvenance != nil {
+			srcNode = srcProvenance.root
+			dstProvenance.secrets = append(dstProvenance.secrets, srcProvenance.secrets...)
+		}
+	}
+	mergeMap(dst, src, "", opts, dstNode, srcNode)
+	return dst
+}
+
+// 以下函数中 srcNode 为 nil 表示不记录来源，此时 dstNode 也为 nil
+// 记录来源时 srcNode 总是非 nil，dstNode 为 nil 表示现有值的来源未知
+
+func mergeMap(dst, src map[string]interface{}, path string, opts MergeOptions, dstNode, srcNode *originNode) {
+	for k, v := range src {
+		if k == DeleteSentinel {
+			continue
+		}
+		childPath := joinPath(path, k)
+		if isDelete(v) {
+			delete(dst, k)
+			if dstNode != nil {
+				delete(dstNode.children, k)
+			}
+			continue
+		}
+		var existingNode *originNode
+		if dstNode != nil {
+			existingNode = dstNode.children[k]
+		}
+		value, node := mergeValue(dst[k], v, childPath, opts, existingNode, srcNode.child(k))
+		dst[k] = value
+		if dstNode != nil {
+			dstNode.setChild(k, node)
+		}
+	}
+}
+
+func mergeValue(dst, src interface{}, path string, opts MergeOptions, dstNode, srcNode *originNode) (interface{}, *originNode) {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		d, ok := dst.(map[string]interface{})
+		if !ok {
+			d = make(map[string]interface{}, len(s))
+			dstNode = nil
+		}
+		if srcNode != nil {
+			if dstNode == nil {
+				dstNode = &originNode{}
+			}
+			// 映射的来源为最后一个定义它的来源
+			if srcNode.origin != (Origin{}) {
+				dstNode.origin = srcNode.origin
+			}
+		}
+		mergeMap(d, s, path, opts, dstNode, srcNode)
+		return d, dstNode
+	case []interface{}:
+		d, ok := dst.([]interface{})
+		if !ok {
+			dstNode = nil
+		}
+		return mergeList(d, s, path, opts, dstNode, srcNode)
+	default:
+		if srcNode == nil {
+			return src, nil
+		}
+		return src, &originNode{origin: srcNode.origin}
+	}
+}
+
+func mergeList(dst, src []interface{}, path string, opts MergeOptions, dstNode, srcNode *originNode) ([]interface{}, *originNode) {
+	strategy := opts.listStrategy(path)
+	tracking := srcNode != nil
+
+	// nodes 与 result 一一对应，记录每个列表项的来源
+	var result []interface{}
+	var nodes []*originNode
+	add := func(v interface{}, n *originNode) {
+		result = append(result, v)
+		if tracking {
+			nodes = append(nodes, n)
+		}
+	}
+	keepExisting := func() {
+		for i, item := range dst {
+			var n *originNode
+			if dstNode != nil && i < len(dstNode.items) {
+				n = dstNode.items[i]
+			}
+			add(item, n)
+		}
+	}
+
+	switch strategy.Mode {
+	case ListAppend:
+		keepExisting()
+	case ListMergeByKey:
+		keepExisting()
+		for j, item := range src {
+			m, ok := item.(map[string]interface{})
+			key, hasKey := m[strategy.Key]
+			if !ok || !hasKey {
+				add(mergeValue(nil, item, path, opts, nil, srcNode.item(j)))
+				continue
+			}
+
+			index := findByKey(result, strategy.Key, key)
+			// 带有 ~delete: true 的项删除匹配的列表项
+			if m[DeleteSentinel] == true {
+				if index >= 0 {
+					result = append(result[:index], result[index+1:]...)
+					if tracking {
+						nodes = append(nodes[:index], nodes[index+1:]...)
+					}
+				}
+				continue
+			}
+			if index >= 0 {
+				var existingNode *originNode
+				if tracking {
+					existingNode = nodes[index]
+				}
+				value, node := mergeValue(result[index], m, path, opts, existingNode, srcNode.item(j))
+				result[index] = value
+				if tracking {
+					nodes[index] = node
+				}
+			} else {
+				add(mergeValue(nil, m, path, opts, nil, srcNode.item(j)))
+			}
+		}
+		return result, listNode(srcNode, dstNode, nodes)
+	}
+
+	for j, item := range src {
+		if isDelete(item) {
+			continue
+		}
+		add(mergeValue(nil, item, path, opts, nil, srcNode.item(j)))
+	}
+	if result == nil {
+		result = []interface{}{}
+	}
+	return result, listNode(srcNode, dstNode, nodes)
+}
+
+// listNode 返回合并后列表的来源，列表本身的来源为最后一个定义它的来源
+func listNode(srcNode, dstNode *originNode, items []*originNode) *originNode {
+	if srcNode == nil {
+		return nil
+	}
+	n := &originNode{origin: srcNode.origin, items: items}
+	if n.origin == (Origin{}) && dstNode != nil {
+		n.origin = dstNode.origin
+	}
+	return n
+}
+
+// copyValue 复制映射和列表，并移除其中的删除标记
+func copyValue(v interface{}, path string, opts MergeOptions) interface{} {
+	value, _ := mergeValue(nil, v, path, opts, nil, nil)
+	return value
+}
+
+// findByKey 返回列表中字段 key 的值等于 value 的映射项下标
+func findByKey(items []interface{}, key string, value interface{}) int {
+	for i, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			if v, ok := m[key]; ok && fmt.Sprint(v) == fmt.Sprint(value) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isDelete(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && s == DeleteSentinel
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
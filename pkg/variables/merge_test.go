@@ -0,0 +1,155 @@
+package variables
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseListStrategy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ListStrategy
+		wantErr bool
+	}{
+		{"", ListStrategy{Mode: ListReplace}, false},
+		{"replace", ListStrategy{Mode: ListReplace}, false},
+		{"append", ListStrategy{Mode: ListAppend}, false},
+		{"merge-by-key", ListStrategy{Mode: ListMergeByKey, Key: "name"}, false},
+		{"merge-by-key:id", ListStrategy{Mode: ListMergeByKey, Key: "id"}, false},
+		{"merge-by-key:", ListStrategy{}, true},
+		{"append:id", ListStrategy{}, true},
+		{"unknown", ListStrategy{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseListStrategy(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseListStrategy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseListStrategy(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		lists     string
+		listPaths map[string]string
+		files     []string
+		want      string
+	}{
+		{
+			name: "deep merge nested maps",
+			files: []string{
+				"database:\n  host: localhost\n  options:\n    ssl: false\n    timeout: 5\n",
+				"database:\n  options:\n    ssl: true\n",
+			},
+			want: "database:\n  host: localhost\n  options:\n    ssl: true\n    timeout: 5\n",
+		},
+		{
+			name: "scalar replaces map",
+			files: []string{
+				"a:\n  b: 1\n",
+				"a: 2\n",
+			},
+			want: "a: 2\n",
+		},
+		{
+			name: "lists are replaced by default",
+			files: []string{
+				"tags: [a, b]\n",
+				"tags: [c]\n",
+			},
+			want: "tags: [c]\n",
+		},
+		{
+			name:  "append lists",
+			lists: "append",
+			files: []string{
+				"tags: [a, b]\n",
+				"tags: [c]\n",
+			},
+			want: "tags: [a, b, c]\n",
+		},
+		{
+			name:      "merge list by key for one path",
+			listPaths: map[string]string{"server.routes": "merge-by-key"},
+			files: []string{
+				"server:\n  routes:\n    - {name: users, path: /users}\n    - {name: posts, path: /posts}\n  tags: [a]\n",
+				"server:\n  routes:\n    - {name: posts, path: /articles}\n    - {name: admin, path: /admin}\n  tags: [b]\n",
+			},
+			want: "server:\n  routes:\n    - {name: users, path: /users}\n    - {name: posts, path: /articles}\n    - {name: admin, path: /admin}\n  tags: [b]\n",
+		},
+		{
+			name:  "merge by custom key and delete item",
+			lists: "merge-by-key:id",
+			files: []string{
+				"items:\n  - {id: 1, v: a}\n  - {id: 2, v: b}\n",
+				"items:\n  - {id: 1, \"~delete\": true}\n  - {id: 2, extra: x}\n",
+			},
+			want: "items:\n  - {id: 2, v: b, extra: x}\n",
+		},
+		{
+			name: "delete sentinel removes keys",
+			files: []string{
+				"database:\n  host: localhost\n  options:\n    ssl: true\n",
+				"database:\n  options: ~delete\n  missing: ~delete\n",
+			},
+			want: "database:\n  host: localhost\n",
+		},
+		{
+			name: "delete sentinel in first file",
+			files: []string{
+				"a: ~delete\nb:\n  c: ~delete\n  d: 1\n",
+			},
+			want: "b:\n  d: 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := NewMergeOptions(tt.lists, tt.listPaths)
+			if err != nil {
+				t.Fatalf("NewMergeOptions() error = %v", err)
+			}
+
+			var result map[string]interface{}
+			for _, file := range tt.files {
+				result = Merge(result, parseYAML(t, file), opts)
+			}
+
+			want := parseYAML(t, tt.want)
+			if !reflect.DeepEqual(result, want) {
+				t.Errorf("Merge() = %v, want %v", result, want)
+			}
+		})
+	}
+}
+
+func TestMerge_DoesNotShareSource(t *testing.T) {
+	src := parseYAML(t, "a:\n  b: 1\nlist: [1]\n")
+	dst := Merge(nil, src, MergeOptions{})
+
+	dst["a"].(map[string]interface{})["b"] = 2
+	dst["list"].([]interface{})[0] = 2
+	if src["a"].(map[string]interface{})["b"] != 1 || src["list"].([]interface{})[0] != 1 {
+		t.Errorf("Merge() result shares data with source: %v", src)
+	}
+}
+
+func parseYAML(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := yaml.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatalf("Failed to parse yaml: %v", err)
+	}
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+	return m
+}
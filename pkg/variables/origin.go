@@ -0,0 +1,66 @@
+package variables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Origin 变量值的来源位置
+type Origin struct {
+	// 来源文件，或 "-set"、"环境变量 GEN_X" 等非文件来源的描述
+	File string `json:"file"`
+	// 行号和列号，从 1 开始，0 表示未知
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+func (o Origin) String() string {
+	if o.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d", o.File, o.Line, o.Column)
+	}
+	return o.File
+}
+
+// LocateFile 返回变量文件中每个变量路径的位置，路径格式与 Override.Path 相同，如 routes[0].name
+// YAML 和 JSON 文件记录行号和列号，其他格式只记录文件
+func LocateFile(spec string) (map[string]Origin, error) {
+	_, provenance, err := LoadFile(spec)
+	if err != nil {
+		return nil, err
+	}
+	return provenance.Origins(), nil
+}
+
+// JoinPath 将映射的键连接为变量路径，键中的 .、[ 和 \ 会被转义
+func JoinPath(keys ...string) string {
+	escaped := make([]string, len(keys))
+	for i, key := range keys {
+		escaped[i] = pathEscaper.Replace(key)
+	}
+	return strings.Join(escaped, ".")
+}
+
+var pathEscaper = strings.NewReplacer(`\`, `\\`, ".", `\.`, "[", `\[`)
+
+// IndexPath 返回列表项的变量路径，如 routes[0]
+func IndexPath(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
+
+// ParentPath 返回变量路径的上一级路径，顶层路径返回空字符串，键中转义的 . 和 [ 不作为分隔符
+func ParentPath(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] != '.' && path[i] != '[' {
+			continue
+		}
+		backslashes := 0
+		for j := i - 1; j >= 0 && path[j] == '\\'; j-- {
+			backslashes++
+		}
+		if backslashes%2 == 0 {
+			return path[:i]
+		}
+	}
+	return ""
+}
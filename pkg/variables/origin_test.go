@@ -0,0 +1,66 @@
+package variables
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLocateFile(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "locate_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	yamlPath := filepath.Join(tempDir, "vars.yaml")
+	if err := os.WriteFile(yamlPath, []byte("project:\n  name: demo\nroutes:\n  - path: /\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	origins, err := LocateFile(yamlPath)
+	if err != nil {
+		t.Fatalf("LocateFile() error = %v", err)
+	}
+	want := map[string]Origin{
+		"project":        {File: yamlPath, Line: 1, Column: 1},
+		"project.name":   {File: yamlPath, Line: 2, Column: 3},
+		"routes":         {File: yamlPath, Line: 3, Column: 1},
+		"routes[0]":      {File: yamlPath, Line: 4, Column: 5},
+		"routes[0].path": {File: yamlPath, Line: 4, Column: 5},
+	}
+	if !reflect.DeepEqual(origins, want) {
+		t.Errorf("LocateFile() = %v, want %v", origins, want)
+	}
+
+	// 其他格式只记录文件
+	envPath := filepath.Join(tempDir, "vars.env")
+	if err := os.WriteFile(envPath, []byte("NAME=demo\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	origins, err = LocateFile(envPath)
+	if err != nil {
+		t.Fatalf("LocateFile() error = %v", err)
+	}
+	if !reflect.DeepEqual(origins, map[string]Origin{"NAME": {File: envPath}}) {
+		t.Errorf("LocateFile() = %v", origins)
+	}
+}
+
+func TestParentPath(t *testing.T) {
+	tests := map[string]string{
+		"a":          "",
+		"a.b":        "a",
+		"a.b[0]":     "a.b",
+		"a.b[0].c":   "a.b[0]",
+		"routes[10]": "routes",
+		`a\.b`:       "",
+		`a\.b.c\[0`:  `a\.b`,
+		`a\\.b`:      `a\\`,
+	}
+	for path, want := range tests {
+		if got := ParentPath(path); got != want {
+			t.Errorf("ParentPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package variables
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Provenance 记录变量树中每个值的来源位置，结构与变量树一致
+// 使用 MergeWithProvenance 合并变量时同步更新，列表项追加或按键合并后仍然对应正确的来源
+// 同时记录来源中标记为机密（!secret）的变量路径，合并后仍然保留
+type Provenance struct {
+	root *originNode
+	// 标记为机密的变量路径
+	secrets []string
+}
+
+// originNode 变量树中一个值的来源，映射的键和列表项分别记录在 children 和 items 中
+type originNode struct {
+	origin   Origin
+	children map[string]*originNode
+	items    []*originNode
+}
+
+// NewProvenance 创建空的来源记录
+func NewProvenance() *Provenance {
+	return &Provenance{root: &originNode{}}
+}
+
+// ProvenanceOf 为 vars 中的每个值记录相同的来源
+func ProvenanceOf(vars map[string]interface{}, origin Origin) *Provenance {
+	return &Provenance{root: uniformNode(vars, origin)}
+}
+
+// LoadFile 加载变量文件，同时返回其中每个值的来源
+// YAML 和 JSON 文件记录行号和列号，其他格式只记录文件
+func LoadFile(spec string) (map[string]interface{}, *Provenance, error) {
+	vars, err := DecodeFile(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path, _ := SplitFormat(spec)
+	format := FormatOf(spec)
+	if format != "yaml" && format != "json" {
+		return vars, ProvenanceOf(vars, Origin{File: path}), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "读取变量文件 %s 失败", path)
+	}
+	// JSON 是 YAML 的子集，使用 YAML 节点树获取位置
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, errors.Wrapf(err, "解析变量文件 %s 失败", path)
+	}
+	root := &originNode{}
+	if len(doc.Content) > 0 {
+		root = yamlNode(doc.Content[0], path)
+	}
+	root.origin = Origin{File: path}
+	return vars, &Provenance{root: root, secrets: secretPaths(&doc, "", nil)}, nil
+}
+
+// yamlNode 根据 YAML 节点树创建来源记录，映射的值使用键的位置，列表项使用列表项的位置
+func yamlNode(node *yaml.Node, file string) *originNode {
+	n := &originNode{}
+	switch node.Kind {
+	case yaml.MappingNode:
+		n.children = make(map[string]*originNode)
+		var merged []*originNode
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			// <<: *anchor 合并的键使用锚点处的位置
+			if key.Tag == "!!merge" {
+				merged = append(merged, mergedNodes(value, file)...)
+				continue
+			}
+			child := yamlNode(value, file)
+			child.origin = Origin{File: file, Line: key.Line, Column: key.Column}
+			n.children[key.Value] = child
+		}
+		for _, m := range merged {
+			for k, child := range m.children {
+				if _, ok := n.children[k]; !ok {
+					n.children[k] = child
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			child := yamlNode(item, file)
+			child.origin = Origin{File: file, Line: item.Line, Column: item.Column}
+			n.items = append(n.items, child)
+		}
+	case yaml.AliasNode:
+		if node.Alias != nil {
+			return yamlNode(node.Alias, file)
+		}
+	}
+	return n
+}
+
+// mergedNodes 返回 <<: 合并键引用的映射，前面的映射优先
+func mergedNodes(node *yaml.Node, file string) []*originNode {
+	if node.Kind == yaml.SequenceNode {
+		var nodes []*originNode
+		for _, item := range node.Content {
+			nodes = append(nodes, yamlNode(item, file))
+		}
+		return nodes
+	}
+	return []*originNode{yamlNode(node, file)}
+}
+
+// uniformNode 为值及其包含的所有值记录相同的来源
+func uniformNode(v interface{}, origin Origin) *originNode {
+	n := &originNode{origin: origin}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		n.children = make(map[string]*originNode, len(t))
+		for k, item := range t {
+			n.children[k] = uniformNode(item, origin)
+		}
+	case []interface{}:
+		n.items = make([]*originNode, len(t))
+		for i, item := range t {
+			n.items[i] = uniformNode(item, origin)
+		}
+	}
+	return n
+}
+
+// child 返回映射键的来源，n 为 nil（不记录来源）时返回 nil，键不存在时返回空的来源
+func (n *originNode) child(key string) *originNode {
+	if n == nil {
+		return nil
+	}
+	if c := n.children[key]; c != nil {
+		return c
+	}
+	return &originNode{}
+}
+
+// item 返回列表项的来源，规则同 child
+func (n *originNode) item(i int) *originNode {
+	if n == nil {
+		return nil
+	}
+	if i < len(n.items) && n.items[i] != nil {
+		return n.items[i]
+	}
+	return &originNode{}
+}
+
+func (n *originNode) setChild(key string, child *originNode) {
+	if n.children == nil {
+		n.children = make(map[string]*originNode)
+	}
+	n.children[key] = child
+}
+
+// Lookup 返回变量路径对应的值的来源，路径格式与 Override.Path 相同
+func (p *Provenance) Lookup(path string) (Origin, bool) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return Origin{}, false
+	}
+	n := p.root
+	for _, seg := range segments {
+		if n == nil {
+			return Origin{}, false
+		}
+		if seg.index >= 0 {
+			if seg.index >= len(n.items) {
+				return Origin{}, false
+			}
+			n = n.items[seg.index]
+		} else {
+			n = n.children[seg.key]
+		}
+	}
+	if n == nil || n.origin == (Origin{}) {
+		return Origin{}, false
+	}
+	return n.origin, true
+}
+
+// Secrets 返回在来源中标记为机密的变量路径
+func (p *Provenance) Secrets() []string {
+	return p.secrets
+}
+
+// Origins 返回每个已知来源的变量路径及其来源
+func (p *Provenance) Origins() map[string]Origin {
+	origins := make(map[string]Origin)
+	var walk func(path string, n *originNode)
+	walk = func(path string, n *originNode) {
+		if n == nil {
+			return
+		}
+		if path != "" && n.origin != (Origin{}) {
+			origins[path] = n.origin
+		}
+		for k, child := range n.children {
+			walk(joinEscaped(path, k), child)
+		}
+		for i, item := range n.items {
+			walk(IndexPath(path, i), item)
+		}
+	}
+	walk("", p.root)
+	return origins
+}
+
+// Record 记录变量覆盖（见 ApplyOverrides）后路径上的值的来源
+// 映射值只更新其中包含的键，与覆盖时的深度合并一致；~delete 删除对应的记录
+func (p *Provenance) Record(path string, value interface{}, origin Origin) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	n := p.root
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		if seg.index >= 0 {
+			for len(n.items) <= seg.index {
+				n.items = append(n.items, nil)
+			}
+			if last {
+				n.items[seg.index] = overlayNode(n.items[seg.index], value, origin)
+				return nil
+			}
+			if n.items[seg.index] == nil {
+				n.items[seg.index] = &originNode{}
+			}
+			n = n.items[seg.index]
+			continue
+		}
+
+		if last {
+			if isDelete(value) {
+				delete(n.children, seg.key)
+			} else {
+				n.setChild(seg.key, overlayNode(n.children[seg.key], value, origin))
+			}
+			return nil
+		}
+		if n.children[seg.key] == nil {
+			n.setChild(seg.key, &originNode{})
+		}
+		n = n.children[seg.key]
+	}
+	return nil
+}
+
+// overlayNode 将 value 的来源记录到 n 上，映射逐键更新，其他值整体替换
+func overlayNode(n *originNode, value interface{}, origin Origin) *originNode {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return uniformNode(value, origin)
+	}
+	if n == nil || n.items != nil {
+		n = &originNode{}
+	}
+	n.origin = origin
+	for k, v := range m {
+		if isDelete(v) {
+			delete(n.children, k)
+			continue
+		}
+		n.setChild(k, overlayNode(n.children[k], v, origin))
+	}
+	return n
+}
+
+// EncodeAnnotatedYAML 将变量输出为 YAML，每个键后以注释标注其值的来源，映射的键按名称排序
+//
+//	server: # .gen_variables/common.yaml:1:1
+//	  port: 9090 # .gen_variables/prod.yaml:2:3
+func EncodeAnnotatedYAML(w io.Writer, vars map[string]interface{}, provenance *Provenance) error {
+	var root *originNode
+	if provenance != nil {
+		root = provenance.root
+	}
+	node, err := annotatedNode(vars, root)
+	if err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return errors.Wrap(err, "输出变量失败")
+	}
+	return encoder.Close()
+}
+
+// annotatedNode 创建值的 YAML 节点，子节点带有来源注释
+func annotatedNode(v interface{}, n *originNode) (*yaml.Node, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, k := range sortedKeys(t) {
+			var child *originNode
+			if n != nil {
+				child = n.children[k]
+			}
+			value, err := annotatedNode(t[k], child)
+			if err != nil {
+				return nil, err
+			}
+			key := &yaml.Node{Kind: yaml.ScalarNode, Value: k}
+			// 块格式的映射和列表的注释只能放在键之后
+			if isBlock(value) {
+				key.LineComment = child.comment()
+			} else {
+				value.LineComment = child.comment()
+			}
+			node.Content = append(node.Content, key, value)
+		}
+		if len(node.Content) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		return node, nil
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i, item := range t {
+			var child *originNode
+			if n != nil && i < len(n.items) {
+				child = n.items[i]
+			}
+			value, err := annotatedNode(item, child)
+			if err != nil {
+				return nil, err
+			}
+			// 映射列表项中的每个键已有注释
+			if !isBlock(value) {
+				value.LineComment = child.comment()
+			}
+			node.Content = append(node.Content, value)
+		}
+		if len(node.Content) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		return node, nil
+	default:
+		node := &yaml.Node{}
+		if err := node.Encode(v); err != nil {
+			return nil, errors.Wrapf(err, "无法输出值 %v", v)
+		}
+		return node, nil
+	}
+}
+
+func isBlock(node *yaml.Node) bool {
+	return (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) && len(node.Content) > 0
+}
+
+// comment 返回来源注释，来源未知时为空字符串
+func (n *originNode) comment() string {
+	if n == nil || n.origin == (Origin{}) {
+		return ""
+	}
+	return n.origin.String()
+}
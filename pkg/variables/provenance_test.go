@@ -0,0 +1,194 @@
+package variables
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeWithProvenance(t *testing.T) {
+	a := Origin{File: "a.yaml"}
+	b := Origin{File: "b.yaml"}
+
+	tests := []struct {
+		name string
+		opts MergeOptions
+		dst  string
+		src  string
+		want map[string]Origin
+	}{
+		{
+			name: "maps are merged key by key",
+			dst:  "server: {host: localhost, port: 80}\n",
+			src:  "server: {port: 8080}\n",
+			want: map[string]Origin{"server": b, "server.host": a, "server.port": b},
+		},
+		{
+			name: "replaced list",
+			dst:  "tags: [x, y]\n",
+			src:  "tags: [z]\n",
+			want: map[string]Origin{"tags": b, "tags[0]": b},
+		},
+		{
+			name: "appended list items keep their origin",
+			opts: MergeOptions{Lists: ListStrategy{Mode: ListAppend}},
+			dst:  "tags: [x]\n",
+			src:  "tags: [y, ~delete]\n",
+			want: map[string]Origin{"tags": b, "tags[0]": a, "tags[1]": b},
+		},
+		{
+			name: "merge by key follows the matched item",
+			opts: MergeOptions{Lists: ListStrategy{Mode: ListMergeByKey, Key: "name"}},
+			dst:  "routes: [{name: a, path: /a}, {name: b, path: /b}, {name: c, path: /c}]\n",
+			src:  "routes: [{name: a, ~delete: true}, {name: c, auth: true}]\n",
+			want: map[string]Origin{
+				"routes":         b,
+				"routes[0]":      a,
+				"routes[0].name": a,
+				"routes[0].path": a,
+				"routes[1]":      b,
+				"routes[1].name": b,
+				"routes[1].path": a,
+				"routes[1].auth": b,
+			},
+		},
+		{
+			name: "deleted keys",
+			dst:  "server: {host: localhost, port: 80}\n",
+			src:  "server: {host: ~delete}\n",
+			want: map[string]Origin{"server": b, "server.port": a},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := parseYAML(t, tt.dst)
+			provenance := ProvenanceOf(dst, a)
+			src := parseYAML(t, tt.src)
+			MergeWithProvenance(dst, src, provenance, ProvenanceOf(src, b), tt.opts)
+			if got := provenance.Origins(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Origins() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 来源未知的值不保留被替换的来源
+	dst := parseYAML(t, "name: x\n")
+	provenance := ProvenanceOf(dst, a)
+	MergeWithProvenance(dst, parseYAML(t, "name: y\n"), provenance, nil, MergeOptions{})
+	if origin, ok := provenance.Lookup("name"); ok {
+		t.Errorf("Lookup() = %v, want unknown", origin)
+	}
+}
+
+func TestProvenance_Record(t *testing.T) {
+	a := Origin{File: "a.yaml"}
+	set := Origin{File: "-set"}
+	provenance := ProvenanceOf(parseYAML(t, "server: {host: localhost, port: 80}\nroutes: [{path: /}]\n"), a)
+
+	records := []struct {
+		path  string
+		value interface{}
+	}{
+		{"server", map[string]interface{}{"port": 8080}},
+		{"routes[1].path", "/admin"},
+		{"server.host", DeleteSentinel},
+	}
+	for _, r := range records {
+		if err := provenance.Record(r.path, r.value, set); err != nil {
+			t.Fatalf("Record(%s) error = %v", r.path, err)
+		}
+	}
+
+	want := map[string]Origin{
+		"server":         set,
+		"server.port":    set,
+		"routes":         a,
+		"routes[0]":      a,
+		"routes[0].path": a,
+		"routes[1].path": set,
+	}
+	if got := provenance.Origins(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Origins() = %v, want %v", got, want)
+	}
+}
+
+func TestProvenance_OriginsEscapedKeys(t *testing.T) {
+	a := Origin{File: "a.yaml"}
+	provenance := ProvenanceOf(parseYAML(t, "app.io: 1\napp: {io: 2}\n"), a)
+
+	// 键中的点被转义，与嵌套的键区分开
+	want := map[string]Origin{
+		`app\.io`: a,
+		"app":     a,
+		"app.io":  a,
+	}
+	got := provenance.Origins()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Origins() = %v, want %v", got, want)
+	}
+	for path := range got {
+		if _, ok := provenance.Lookup(path); !ok {
+			t.Errorf("Lookup(%s) found no origin for a path returned by Origins()", path)
+		}
+	}
+}
+
+func TestLoadFile_MergeKeys(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "provenance_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "vars.yaml")
+	content := "base: &base\n  port: 80\n  host: localhost\nprod:\n  <<: *base\n  host: example.com\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	_, provenance, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	// 合并键引入的值指向锚点处的定义
+	tests := map[string]Origin{
+		"prod.port": {File: path, Line: 2, Column: 3},
+		"prod.host": {File: path, Line: 6, Column: 3},
+	}
+	for p, want := range tests {
+		if got, ok := provenance.Lookup(p); !ok || got != want {
+			t.Errorf("Lookup(%s) = %v, want %v", p, got, want)
+		}
+	}
+	if _, ok := provenance.Lookup("prod.<<"); ok {
+		t.Error("Lookup(prod.<<) found the merge key")
+	}
+}
+
+func TestEncodeAnnotatedYAML(t *testing.T) {
+	vars := parseYAML(t, "server: {port: 80, tags: [a]}\nroutes: [{path: /}]\nempty: {}\n")
+	provenance := ProvenanceOf(vars, Origin{File: "a.yaml"})
+	if err := provenance.Record("server.port", 8080, Origin{File: "b.yaml", Line: 2, Column: 3}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	vars["server"].(map[string]interface{})["port"] = 8080
+
+	var buf bytes.Buffer
+	if err := EncodeAnnotatedYAML(&buf, vars, provenance); err != nil {
+		t.Fatalf("EncodeAnnotatedYAML() error = %v", err)
+	}
+	want := `empty: {} # a.yaml
+routes: # a.yaml
+  - path: / # a.yaml
+server: # a.yaml
+  port: 8080 # b.yaml:2:3
+  tags: # a.yaml
+    - a # a.yaml
+`
+	if buf.String() != want {
+		t.Errorf("EncodeAnnotatedYAML() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,404 @@
+package variables
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/pkg/errors"
+)
+
+// ComputedKey 变量中保存计算变量的顶层键，其中的每个字符串都是 Go 模板，
+// 渲染结果设置到去掉 computed 前缀的同名路径上：
+//
+//	project:
+//	  name: demo
+//	computed:
+//	  module: "github.com/acme/{{ .project.name }}"
+//	  project:
+//	    title: "{{ .project.name | ucfirst }}"
+const ComputedKey = "computed"
+
+// Resolve 展开变量中的 ${路径} 引用并计算 computed 中的变量，修改 vars 本身
+//
+// 字符串中的 ${project.name} 替换为对应变量的值，整个字符串只是一个引用时保留值的类型，
+// $${ 表示字符 ${。computed 中的模板使用 funcs 中的函数，以合并后的变量为数据渲染，
+// 计算完成后 computed 键被删除。引用之间按依赖顺序求值，存在循环引用时返回错误。
+// provenance 不为 nil 时，计算变量的来源记录为其在 computed 中的定义位置
+func Resolve(vars map[string]interface{}, funcs template.FuncMap, provenance *Provenance) error {
+	nodes := make(map[string]*resolveNode)
+
+	if section, ok := vars[ComputedKey]; ok {
+		m, ok := section.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("%s 必须是映射，实际为 %s", ComputedKey, typeName(section))
+		}
+		if err := collectComputed(nodes, "", m, funcs, provenance); err != nil {
+			return err
+		}
+		delete(vars, ComputedKey)
+		if provenance != nil {
+			delete(provenance.root.children, ComputedKey)
+		}
+	}
+	if err := collectInterpolated(nodes, "", vars, provenance); err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	r := &resolver{vars: vars, nodes: nodes, state: make(map[string]int)}
+	for _, path := range sortedKeys(nodes) {
+		if err := r.resolve(path, nil); err != nil {
+			return err
+		}
+	}
+	if provenance != nil {
+		for _, path := range sortedKeys(nodes) {
+			n := nodes[path]
+			if n.computed || isContainer(n.value) {
+				if err := provenance.Record(path, n.value, n.origin); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveNode 一个需要求值的变量：computed 中的模板或包含 ${} 引用的字符串
+type resolveNode struct {
+	path     string
+	computed bool
+	// computed 中的模板，非字符串的值不需要求值
+	tmpl *template.Template
+	// ${} 字符串拆分后的片段
+	parts []interpolationPart
+	// 引用的变量路径，空字符串表示引用全部变量
+	refs   []string
+	origin Origin
+	// 求值结果
+	value interface{}
+}
+
+func (n *resolveNode) String() string {
+	if n.origin != (Origin{}) {
+		return fmt.Sprintf("%s (%s)", n.path, n.origin)
+	}
+	return n.path
+}
+
+// collectComputed 收集 computed 中的变量，嵌套的映射表示嵌套的变量路径
+func collectComputed(nodes map[string]*resolveNode, path string, m map[string]interface{}, funcs template.FuncMap, provenance *Provenance) error {
+	for _, k := range sortedKeys(m) {
+		p := joinEscaped(path, k)
+		if child, ok := m[k].(map[string]interface{}); ok && len(child) > 0 {
+			if err := collectComputed(nodes, p, child, funcs, provenance); err != nil {
+				return err
+			}
+			continue
+		}
+
+		n := &resolveNode{path: p, computed: true, value: m[k]}
+		if provenance != nil {
+			n.origin, _ = provenance.Lookup(JoinPath(ComputedKey) + "." + p)
+		}
+		if text, ok := m[k].(string); ok {
+			tmpl, err := template.New(p).Funcs(funcs).Option("missingkey=error").Parse(text)
+			if err != nil {
+				return errors.Wrapf(err, "解析计算变量 %s 失败", n)
+			}
+			n.tmpl = tmpl
+			if tmpl.Tree != nil {
+				n.refs = templateRefs(tmpl.Tree.Root, true, nil)
+			}
+		}
+		nodes[p] = n
+	}
+	return nil
+}
+
+// collectInterpolated 收集包含 ${ 的字符串，已由 computed 设置的路径除外
+func collectInterpolated(nodes map[string]*resolveNode, path string, v interface{}, provenance *Provenance) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(t) {
+			if err := collectInterpolated(nodes, joinEscaped(path, k), t[k], provenance); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range t {
+			if err := collectInterpolated(nodes, IndexPath(path, i), item, provenance); err != nil {
+				return err
+			}
+		}
+	case string:
+		if !strings.Contains(t, "${") || overridden(nodes, path) {
+			return nil
+		}
+		n := &resolveNode{path: path, value: t}
+		if provenance != nil {
+			n.origin, _ = provenance.Lookup(path)
+		}
+		parts, err := parseInterpolation(t)
+		if err != nil {
+			return errors.Wrapf(err, "变量 %s", n)
+		}
+		n.parts = parts
+		for _, part := range parts {
+			if part.ref {
+				n.refs = append(n.refs, part.text)
+			}
+		}
+		nodes[path] = n
+	}
+	return nil
+}
+
+// overridden 判断路径是否位于某个计算变量之中，计算结果会替换其中的值
+func overridden(nodes map[string]*resolveNode, path string) bool {
+	for p, n := range nodes {
+		if n.computed && overlaps(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolver 按依赖顺序求值，state 记录每个变量的状态：1 求值中，2 已完成
+type resolver struct {
+	vars  map[string]interface{}
+	nodes map[string]*resolveNode
+	state map[string]int
+}
+
+// resolve 先求值 path 引用的变量，再求值 path 本身，stack 为当前的引用链
+func (r *resolver) resolve(path string, stack []string) error {
+	switch r.state[path] {
+	case 2:
+		return nil
+	case 1:
+		return r.cycleError(append(stack, path))
+	}
+	r.state[path] = 1
+	stack = append(stack, path)
+
+	n := r.nodes[path]
+	for _, dep := range r.dependencies(n) {
+		if err := r.resolve(dep, stack); err != nil {
+			return err
+		}
+	}
+
+	value, err := r.evaluate(n)
+	if err != nil {
+		return err
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	if _, err := setPath(r.vars, segments, value, MergeOptions{}); err != nil {
+		return errors.Wrapf(err, "设置变量 %s 失败", n)
+	}
+	n.value = value
+	r.state[path] = 2
+	return nil
+}
+
+// dependencies 返回 n 引用的其他需要求值的变量，引用一个映射时依赖其中所有需要求值的变量
+func (r *resolver) dependencies(n *resolveNode) []string {
+	var deps []string
+	for _, path := range sortedKeys(r.nodes) {
+		for _, ref := range n.refs {
+			if (ref == "" && path != n.path) || (ref != "" && overlaps(ref, path)) {
+				deps = append(deps, path)
+				break
+			}
+		}
+	}
+	return deps
+}
+
+func (r *resolver) cycleError(stack []string) error {
+	start := 0
+	for i, path := range stack[:len(stack)-1] {
+		if path == stack[len(stack)-1] {
+			start = i
+		}
+	}
+	chain := make([]string, 0, len(stack)-start)
+	for _, path := range stack[start:] {
+		chain = append(chain, r.nodes[path].String())
+	}
+	return errors.Errorf("变量存在循环引用: %s", strings.Join(chain, " -> "))
+}
+
+// evaluate 求值单个变量，其引用的变量都已求值
+func (r *resolver) evaluate(n *resolveNode) (interface{}, error) {
+	if n.computed {
+		if n.tmpl == nil {
+			return n.value, nil
+		}
+		var result strings.Builder
+		if err := n.tmpl.Execute(&result, r.vars); err != nil {
+			return nil, errors.Wrapf(err, "计算变量 %s 失败", n)
+		}
+		return result.String(), nil
+	}
+
+	var result strings.Builder
+	for _, part := range n.parts {
+		if !part.ref {
+			result.WriteString(part.text)
+			continue
+		}
+		value, ok := Get(r.vars, part.text)
+		if !ok {
+			return nil, errors.Errorf("变量 %s 引用的变量 %s 不存在", n, part.text)
+		}
+		// 整个字符串只是一个引用时保留值的类型
+		if len(n.parts) == 1 {
+			return copyValue(value, "", MergeOptions{}), nil
+		}
+		result.WriteString(fmt.Sprint(value))
+	}
+	return result.String(), nil
+}
+
+// interpolationPart ${} 字符串中的一段，ref 为 true 时 text 为引用的变量路径
+type interpolationPart struct {
+	text string
+	ref  bool
+}
+
+// EscapeInterpolation 将字符串中的 ${ 转义为 $${，使 Resolve 按原样保留字符串
+func EscapeInterpolation(s string) string {
+	return strings.ReplaceAll(s, "${", "$${")
+}
+
+// parseInterpolation 将字符串拆分为文本和 ${路径} 引用，$${ 表示字符 ${
+func parseInterpolation(s string) ([]interpolationPart, error) {
+	var parts []interpolationPart
+	var text strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], "$${") {
+			text.WriteString("${")
+			i += 2
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			text.WriteByte(s[i])
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return nil, errors.Errorf("引用没有结束的 }: %s", s)
+		}
+		ref := strings.TrimSpace(s[i+2 : i+end])
+		segments, err := parsePath(ref)
+		if err != nil {
+			return nil, err
+		}
+		if text.Len() > 0 {
+			parts = append(parts, interpolationPart{text: text.String()})
+			text.Reset()
+		}
+		parts = append(parts, interpolationPart{text: canonicalPath(segments), ref: true})
+		i += end
+	}
+	if text.Len() > 0 || len(parts) == 0 {
+		parts = append(parts, interpolationPart{text: text.String()})
+	}
+	return parts, nil
+}
+
+// templateRefs 返回模板中引用的变量路径，rooted 表示 . 是否仍为全部变量（不在 range 或 with 之中）
+func templateRefs(node parse.Node, rooted bool, refs []string) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return refs
+		}
+		for _, child := range n.Nodes {
+			refs = templateRefs(child, rooted, refs)
+		}
+	case *parse.ActionNode:
+		refs = templateRefs(n.Pipe, rooted, refs)
+	case *parse.PipeNode:
+		if n == nil {
+			return refs
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				refs = templateRefs(arg, rooted, refs)
+			}
+		}
+	case *parse.ChainNode:
+		refs = templateRefs(n.Node, rooted, refs)
+	case *parse.FieldNode:
+		if rooted {
+			refs = append(refs, JoinPath(n.Ident...))
+		}
+	case *parse.DotNode:
+		if rooted {
+			refs = append(refs, "")
+		}
+	case *parse.VariableNode:
+		// $ 始终是全部变量
+		if n.Ident[0] == "$" {
+			refs = append(refs, JoinPath(n.Ident[1:]...))
+		}
+	case *parse.IfNode:
+		refs = templateRefs(n.Pipe, rooted, refs)
+		refs = templateRefs(n.List, rooted, refs)
+		refs = templateRefs(n.ElseList, rooted, refs)
+	case *parse.RangeNode:
+		refs = templateRefs(n.Pipe, rooted, refs)
+		refs = templateRefs(n.List, false, refs)
+		refs = templateRefs(n.ElseList, rooted, refs)
+	case *parse.WithNode:
+		refs = templateRefs(n.Pipe, rooted, refs)
+		refs = templateRefs(n.List, false, refs)
+		refs = templateRefs(n.ElseList, rooted, refs)
+	case *parse.TemplateNode:
+		refs = templateRefs(n.Pipe, rooted, refs)
+	}
+	return refs
+}
+
+// overlaps 判断两个变量路径是否相同或一个包含另一个
+func overlaps(a, b string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return a == b || strings.HasPrefix(b, a+".") || strings.HasPrefix(b, a+"[")
+}
+
+// canonicalPath 将解析后的变量路径转换为 JoinPath 和 IndexPath 的格式
+func canonicalPath(segments []pathSegment) string {
+	path := ""
+	for _, seg := range segments {
+		if seg.index >= 0 {
+			path = IndexPath(path, seg.index)
+		} else {
+			path = joinEscaped(path, seg.key)
+		}
+	}
+	return path
+}
+
+func joinEscaped(path, key string) string {
+	return joinPath(path, JoinPath(key))
+}
+
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package variables
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestResolve(t *testing.T) {
+	funcs := template.FuncMap{"upper": strings.ToUpper}
+
+	tests := []struct {
+		name    string
+		vars    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "interpolation",
+			vars: "project: {name: demo}\nmodule: github.com/acme/${project.name}\n",
+			want: "project: {name: demo}\nmodule: github.com/acme/demo\n",
+		},
+		{
+			name: "single reference keeps the type",
+			vars: "server: {port: 80, tags: [a]}\nport: ${server.port}\ntags: ${ server.tags }\n",
+			want: "server: {port: 80, tags: [a]}\nport: 80\ntags: [a]\n",
+		},
+		{
+			name: "escaped reference",
+			vars: "shell: echo $${HOME} ${name}\nname: x\n",
+			want: "shell: echo ${HOME} x\nname: x\n",
+		},
+		{
+			name: "references in lists and chains",
+			vars: "routes: [{path: /api}, {path: \"${routes[0].path}/v2\"}]\nurl: http://host${routes[1].path}\n",
+			want: "routes: [{path: /api}, {path: /api/v2}]\nurl: http://host/api/v2\n",
+		},
+		{
+			name: "computed values are resolved in dependency order",
+			vars: "project: {name: demo}\ncomputed:\n  title: \"{{ .module | upper }}\"\n  module: \"{{ .prefix }}/{{ .project.name }}\"\n  project: {id: \"{{ .title }}-1\"}\nprefix: github.com/acme\n",
+			want: "project: {name: demo, id: GITHUB.COM/ACME/DEMO-1}\ntitle: GITHUB.COM/ACME/DEMO\nmodule: github.com/acme/demo\nprefix: github.com/acme\n",
+		},
+		{
+			name: "computed values replace interpolated values",
+			vars: "name: ${missing}\ncomputed: {name: \"{{ .base }}\", port: 8080}\nbase: x\n",
+			want: "name: x\nport: 8080\nbase: x\n",
+		},
+		{
+			name: "interpolation sees computed values",
+			vars: "computed: {host: \"{{ .name }}.local\"}\nname: demo\nurl: http://${host}\n",
+			want: "host: demo.local\nname: demo\nurl: http://demo.local\n",
+		},
+		{
+			name:    "cycle",
+			vars:    "a: ${b}\nb: \"${c}x\"\ncomputed: {c: \"{{ .a }}\"}\n",
+			wantErr: "变量存在循环引用: a -> b -> c -> a",
+		},
+		{
+			name:    "self reference",
+			vars:    "name: ${name}-x\n",
+			wantErr: "变量存在循环引用: name -> name",
+		},
+		{
+			name:    "missing reference",
+			vars:    "name: ${project.name}\n",
+			wantErr: "变量 name 引用的变量 project.name 不存在",
+		},
+		{
+			name:    "missing key in computed template",
+			vars:    "computed: {name: \"{{ .project.name }}\"}\n",
+			wantErr: "计算变量 name 失败",
+		},
+		{
+			name:    "unterminated reference",
+			vars:    "name: ${project.name\n",
+			wantErr: "引用没有结束的 }",
+		},
+		{
+			name:    "computed is not a map",
+			vars:    "computed: [a]\n",
+			wantErr: "computed 必须是映射",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := parseYAML(t, tt.vars)
+			err := Resolve(vars, funcs, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if want := parseYAML(t, tt.want); !reflect.DeepEqual(vars, want) {
+				t.Errorf("Resolve() = %v, want %v", vars, want)
+			}
+		})
+	}
+}
+
+func TestResolve_Provenance(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "resolve_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "vars.yaml")
+	content := "a: ${b}\nb: ${c}\ncomputed:\n  c: \"{{ .a }}\"\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	vars, provenance, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	// 循环引用的错误包含每个变量的定义位置
+	err = Resolve(vars, nil, provenance)
+	want := "变量存在循环引用: a (" + path + ":1:1) -> b (" + path + ":2:1) -> c (" + path + ":4:3) -> a (" + path + ":1:1)"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Resolve() error = %v, want %s", err, want)
+	}
+
+	content = "server: {port: 80}\nport: ${server}\ncomputed:\n  url: \"http://localhost:{{ .server.port }}\"\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if vars, provenance, err = LoadFile(path); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if err := Resolve(vars, nil, provenance); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	// 计算变量的来源为其在 computed 中的定义，展开的映射沿用引用所在的位置
+	wantOrigins := map[string]Origin{
+		"server":      {File: path, Line: 1, Column: 1},
+		"server.port": {File: path, Line: 1, Column: 10},
+		"port":        {File: path, Line: 2, Column: 1},
+		"port.port":   {File: path, Line: 2, Column: 1},
+		"url":         {File: path, Line: 4, Column: 3},
+	}
+	if got := provenance.Origins(); !reflect.DeepEqual(got, wantOrigins) {
+		t.Errorf("Origins() = %v, want %v", got, wantOrigins)
+	}
+}
@@ -0,0 +1,406 @@
+package variables
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Schema 变量校验规则，支持 JSON Schema 的常用子集，可以用 YAML 或 JSON 编写：
+//
+//	type: object
+//	required: [project]
+//	properties:
+//	  project:
+//	    type: object
+//	    required: [name]
+//	    properties:
+//	      name: {type: string, pattern: "^[a-z][a-z0-9-]*$"}
+//	      port: {type: integer, minimum: 1, maximum: 65535, default: 8080}
+//	      token: {type: string, secret: true}
+//
+// secret 为扩展关键字，将变量标记为机密，见 Secrets
+// 未支持的关键字（如 $schema、title、description）会被忽略
+type Schema struct {
+	Type                 SchemaTypes        `yaml:"type" json:"type"`
+	Properties           map[string]*Schema `yaml:"properties" json:"properties"`
+	Required             []string           `yaml:"required" json:"required"`
+	AdditionalProperties *bool              `yaml:"additionalProperties" json:"additionalProperties"`
+	Items                *Schema            `yaml:"items" json:"items"`
+	Enum                 []interface{}      `yaml:"enum" json:"enum"`
+	Default              interface{}        `yaml:"default" json:"default"`
+	Minimum              *float64           `yaml:"minimum" json:"minimum"`
+	Maximum              *float64           `yaml:"maximum" json:"maximum"`
+	MinLength            *int               `yaml:"minLength" json:"minLength"`
+	MaxLength            *int               `yaml:"maxLength" json:"maxLength"`
+	Pattern              string             `yaml:"pattern" json:"pattern"`
+	MinItems             *int               `yaml:"minItems" json:"minItems"`
+	MaxItems             *int               `yaml:"maxItems" json:"maxItems"`
+	Secret               bool               `yaml:"secret" json:"secret"`
+
+	pattern *regexp.Regexp
+}
+
+// SchemaTypes 允许的类型，可以写为单个类型或类型列表：
+// string, integer, number, boolean, object, array, null
+type SchemaTypes []string
+
+// UnmarshalYAML 支持单个类型和类型列表
+func (t *SchemaTypes) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = SchemaTypes{node.Value}
+		return nil
+	}
+	var types []string
+	if err := node.Decode(&types); err != nil {
+		return err
+	}
+	*t = types
+	return nil
+}
+
+// UnmarshalJSON 支持单个类型和类型列表
+func (t *SchemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaTypes{single}
+		return nil
+	}
+	var types []string
+	if err := json.Unmarshal(data, &types); err != nil {
+		return err
+	}
+	*t = types
+	return nil
+}
+
+var schemaTypes = map[string]bool{
+	"string": true, "integer": true, "number": true, "boolean": true,
+	"object": true, "array": true, "null": true,
+}
+
+// LoadSchema 加载变量校验规则文件，.json 文件按 JSON 解析，其他按 YAML 解析
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取变量校验规则文件 %s 失败", path)
+	}
+
+	var schema Schema
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &schema)
+	} else {
+		err = yaml.Unmarshal(data, &schema)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "解析变量校验规则文件 %s 失败", path)
+	}
+	if err := schema.compile(""); err != nil {
+		return nil, errors.Wrapf(err, "变量校验规则文件 %s 无效", path)
+	}
+	return &schema, nil
+}
+
+// compile 检查规则并编译正则表达式，JSON 中的数值转换为与变量一致的类型
+func (s *Schema) compile(path string) error {
+	for _, t := range s.Type {
+		if !schemaTypes[t] {
+			return errors.Errorf("%s: 未知的类型 %s", displayPath(path), t)
+		}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return errors.Wrapf(err, "%s: 无效的 pattern", displayPath(path))
+		}
+		s.pattern = re
+	}
+	s.Default = NormalizeJSON(s.Default)
+	for i, v := range s.Enum {
+		s.Enum[i] = NormalizeJSON(v)
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return errors.Errorf("%s: 属性规则为空", displayPath(joinEscaped(path, name)))
+		}
+		if err := prop.compile(joinEscaped(path, name)); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(path + "[]")
+	}
+	return nil
+}
+
+// ApplyDefaults 为 vars 中不存在的键设置规则中声明的默认值，返回设置了默认值的变量路径
+// 默认值只会设置到已存在的映射中（顶层映射总是存在），也会应用到列表中的每一项
+func (s *Schema) ApplyDefaults(vars map[string]interface{}) []string {
+	var paths []string
+	s.applyDefaults("", vars, &paths)
+	return paths
+}
+
+func (s *Schema) applyDefaults(path string, v interface{}, paths *[]string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, name := range sortedKeys(s.Properties) {
+			prop := s.Properties[name]
+			childPath := joinEscaped(path, name)
+			if _, ok := t[name]; !ok && prop.Default != nil {
+				t[name] = copyValue(prop.Default, "", MergeOptions{})
+				*paths = append(*paths, childPath)
+			}
+			if child, ok := t[name]; ok {
+				prop.applyDefaults(childPath, child, paths)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range t {
+				s.Items.applyDefaults(IndexPath(path, i), item, paths)
+			}
+		}
+	}
+}
+
+// SecretPaths 返回 vars 中被规则标记为机密（secret: true）的变量路径，列表中的每一项分别返回
+func (s *Schema) SecretPaths(vars map[string]interface{}) []string {
+	var paths []string
+	s.secretPaths("", vars, &paths)
+	return paths
+}
+
+func (s *Schema) secretPaths(path string, v interface{}, paths *[]string) {
+	if s.Secret && path != "" {
+		*paths = append(*paths, path)
+		return
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, name := range sortedKeys(s.Properties) {
+			if child, ok := t[name]; ok {
+				s.Properties[name].secretPaths(joinEscaped(path, name), child, paths)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range t {
+				s.Items.secretPaths(IndexPath(path, i), item, paths)
+			}
+		}
+	}
+}
+
+// Violation 一处变量校验错误
+type Violation struct {
+	// 变量路径，如 project.name 或 routes[0].path，空字符串表示顶层
+	Path    string
+	Message string
+	// 变量的来源位置，未知时为 nil
+	Origin *Origin
+}
+
+func (v Violation) String() string {
+	s := displayPath(v.Path) + ": " + v.Message
+	if v.Origin != nil {
+		s += " (" + v.Origin.String() + ")"
+	}
+	return s
+}
+
+// ValidationError 变量校验失败，包含所有校验错误
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "变量校验失败，共 %d 处错误:", len(e.Violations))
+	for _, v := range e.Violations {
+		sb.WriteString("\n  ")
+		sb.WriteString(v.String())
+	}
+	return sb.String()
+}
+
+// Validate 校验变量，返回所有校验错误（按发现顺序，属性按名称排序）
+func (s *Schema) Validate(vars map[string]interface{}) []Violation {
+	var violations []Violation
+	s.validate("", vars, &violations)
+	return violations
+}
+
+func (s *Schema) validate(path string, v interface{}, violations *[]Violation) {
+	report := func(format string, args ...interface{}) {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Type) > 0 && !matchesAnyType(v, s.Type) {
+		report("类型应为 %s，实际为 %s", strings.Join(s.Type, " 或 "), typeName(v))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("值 %v 不在允许的取值 %v 中", v, s.Enum)
+		}
+	}
+
+	switch t := v.(type) {
+	case string:
+		length := len([]rune(t))
+		if s.MinLength != nil && length < *s.MinLength {
+			report("长度 %d 小于最小长度 %d", length, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			report("长度 %d 大于最大长度 %d", length, *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(t) {
+			report("值 %q 不匹配 %s", t, s.Pattern)
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := t[name]; !ok {
+				*violations = append(*violations, Violation{Path: joinEscaped(path, name), Message: "缺少必需的变量"})
+			}
+		}
+		for _, name := range sortedKeys(t) {
+			childPath := joinEscaped(path, name)
+			if prop, ok := s.Properties[name]; ok {
+				prop.validate(childPath, t[name], violations)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties && !reserved(path, name) {
+				*violations = append(*violations, Violation{Path: childPath, Message: "未声明的变量"})
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(t) < *s.MinItems {
+			report("列表长度 %d 小于 %d", len(t), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(t) > *s.MaxItems {
+			report("列表长度 %d 大于 %d", len(t), *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range t {
+				s.Items.validate(IndexPath(path, i), item, violations)
+			}
+		}
+	default:
+		if n, ok := toFloat(v); ok {
+			if s.Minimum != nil && n < *s.Minimum {
+				report("值 %v 小于最小值 %v", v, *s.Minimum)
+			}
+			if s.Maximum != nil && n > *s.Maximum {
+				report("值 %v 大于最大值 %v", v, *s.Maximum)
+			}
+		}
+	}
+}
+
+func matchesAnyType(v interface{}, types []string) bool {
+	for _, t := range types {
+		if matchesType(v, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(v interface{}, t string) bool {
+	switch t {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "integer":
+		n, ok := toFloat(v)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := toFloat(v)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "null":
+		return v == nil
+	}
+	return false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if n, ok := toFloat(v); ok {
+		if n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// displayPath 用于错误信息的变量路径，顶层显示为 (root)
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+// reserved 判断键是否不受 additionalProperties 限制：$ 开头的配置项和生成器设置的顶层保留变量（如 __profile）
+func reserved(path, name string) bool {
+	return strings.HasPrefix(name, "$") || (path == "" && strings.HasPrefix(name, "__"))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
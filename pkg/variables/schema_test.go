@@ -0,0 +1,181 @@
+package variables
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testSchema = `
+type: object
+required: [project]
+additionalProperties: false
+properties:
+  $config.allowUndefinedVariables: {type: boolean}
+  project:
+    type: object
+    required: [name]
+    properties:
+      name: {type: string, pattern: "^[a-z][a-z0-9-]*$", maxLength: 10}
+      port: {type: integer, minimum: 1, maximum: 65535, default: 8080}
+      env: {enum: [dev, prod], default: dev}
+  tags:
+    type: array
+    minItems: 1
+    items: {type: string}
+  routes:
+    type: array
+    items:
+      type: object
+      required: [path]
+      properties:
+        path: {type: string}
+        auth: {type: boolean, default: false}
+  ratio: {type: [number, "null"]}
+  example.com: {type: object, required: [host]}
+`
+
+func TestSchema_Validate(t *testing.T) {
+	schema := loadTestSchema(t, "schema.yaml", testSchema)
+
+	tests := []struct {
+		name string
+		vars string
+		want []Violation
+	}{
+		{
+			name: "valid",
+			vars: "project: {name: demo, port: 80}\ntags: [a]\nroutes: [{path: /}]\nratio: ~\n",
+		},
+		{
+			name: "missing required",
+			vars: "tags: [a]\n",
+			want: []Violation{{Path: "project", Message: "缺少必需的变量"}},
+		},
+		{
+			name: "every violation is reported",
+			vars: "project: {port: \"80\", env: test}\ntags: []\nroutes: [{auth: yes}, {path: 1}]\nextra: 1\nratio: 0.5\n",
+			want: []Violation{
+				{Path: "extra", Message: "未声明的变量"},
+				{Path: "project.name", Message: "缺少必需的变量"},
+				{Path: "project.env", Message: "值 test 不在允许的取值 [dev prod] 中"},
+				{Path: "project.port", Message: "类型应为 integer，实际为 string"},
+				{Path: "routes[0].path", Message: "缺少必需的变量"},
+				{Path: "routes[0].auth", Message: "类型应为 boolean，实际为 string"},
+				{Path: "routes[1].path", Message: "类型应为 string，实际为 integer"},
+				{Path: "tags", Message: "列表长度 0 小于 1"},
+			},
+		},
+		{
+			name: "string and number constraints",
+			vars: "project: {name: Very-Long-Name, port: 70000}\n",
+			want: []Violation{
+				{Path: "project.name", Message: "长度 14 大于最大长度 10"},
+				{Path: "project.name", Message: `值 "Very-Long-Name" 不匹配 ^[a-z][a-z0-9-]*$`},
+				{Path: "project.port", Message: "值 70000 大于最大值 65535"},
+			},
+		},
+		{
+			name: "keys containing dots are escaped",
+			vars: "project: {name: demo}\nexample.com: {}\n",
+			want: []Violation{{Path: `example\.com.host`, Message: "缺少必需的变量"}},
+		},
+		{
+			name: "reserved keys are allowed",
+			vars: "project: {name: demo}\n$config.allowUndefinedVariables: true\n__profile: prod\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schema.Validate(parseYAML(t, tt.vars))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema_ApplyDefaults(t *testing.T) {
+	schema := loadTestSchema(t, "schema.yaml", testSchema)
+
+	vars := parseYAML(t, "project: {name: demo, env: prod}\nroutes: [{path: /}, {path: /admin, auth: true}]\n")
+	paths := schema.ApplyDefaults(vars)
+
+	wantPaths := []string{"project.port", "routes[0].auth"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("ApplyDefaults() = %v, want %v", paths, wantPaths)
+	}
+	want := parseYAML(t, "project: {name: demo, env: prod, port: 8080}\nroutes: [{path: /, auth: false}, {path: /admin, auth: true}]\n")
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("ApplyDefaults() = %v, want %v", vars, want)
+	}
+
+	// 不存在的映射不会因为其中的默认值而被创建
+	empty := map[string]interface{}{}
+	schema.ApplyDefaults(empty)
+	if len(empty) != 0 {
+		t.Errorf("ApplyDefaults() = %v, want empty", empty)
+	}
+}
+
+func TestSchema_SecretPaths(t *testing.T) {
+	schema := loadTestSchema(t, "schema.yaml", "properties:\n  db: {properties: {password: {secret: true}}}\n  keys: {items: {secret: true}}\n")
+
+	vars := parseYAML(t, "db: {host: localhost, password: x}\nkeys: [a, b]\n")
+	want := []string{"db.password", "keys[0]", "keys[1]"}
+	if got := schema.SecretPaths(vars); !reflect.DeepEqual(got, want) {
+		t.Errorf("SecretPaths() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	// JSON 格式，数值默认值与 YAML 变量一致为 int
+	schema := loadTestSchema(t, "schema.json", `{"type": "object", "properties": {"port": {"type": "integer", "default": 8080}}}`)
+	vars := map[string]interface{}{}
+	schema.ApplyDefaults(vars)
+	if vars["port"] != 8080 {
+		t.Errorf("ApplyDefaults() = %#v, want port 8080", vars)
+	}
+
+	tempDir, err := os.MkdirTemp("", "schema_invalid_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	invalid := map[string]string{
+		"unknown type":    "type: text\n",
+		"invalid pattern": "properties:\n  a: {pattern: \"[\"}\n",
+		"invalid yaml":    "type: [\n",
+	}
+	for name, content := range invalid {
+		path := filepath.Join(tempDir, "schema.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write schema: %v", err)
+		}
+		if _, err := LoadSchema(path); err == nil {
+			t.Errorf("LoadSchema() %s: expected error", name)
+		}
+	}
+}
+
+func loadTestSchema(t *testing.T, name, content string) *Schema {
+	t.Helper()
+	tempDir, err := os.MkdirTemp("", "schema_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+	schema, err := LoadSchema(path)
+	if err != nil {
+		t.Fatalf("LoadSchema() error = %v", err)
+	}
+	return schema
+}
@@ -0,0 +1,195 @@
+package variables
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// SecretTag YAML 变量文件中将值标记为机密的标签，如 password: !secret s3cr3t
+// 标签不改变值本身，模板得到的仍然是真实的值
+const SecretTag = "!secret"
+
+// RedactedValue 机密值在诊断信息中的替代文本
+const RedactedValue = "******"
+
+// DefaultSecretKeys 默认视为机密的变量键名模式
+var DefaultSecretKeys = []string{"*password*", "*passwd*", "*secret*", "*token*", "*api_key*", "*apikey*", "*private_key*", "*credential*"}
+
+// minRedactLength 在文本中替换的机密值的最短长度，过短的值会误伤无关的文本
+const minRedactLength = 4
+
+// Secrets 判断哪些变量是机密的，并在诊断信息、变量输出等场景中隐藏它们的值
+// 变量在以下情况下是机密的：路径中的某个键匹配键名模式，或者它自身或上级路径被标记为机密
+type Secrets struct {
+	// 小写的键名模式，语法同 path.Match
+	keys  []string
+	paths map[string]bool
+}
+
+// NewSecrets 创建机密变量规则，keys 为键名模式（如 *password*，不区分大小写），paths 为标记为机密的变量路径
+func NewSecrets(keys []string, paths ...string) (*Secrets, error) {
+	s := &Secrets{paths: make(map[string]bool)}
+	for _, key := range keys {
+		key = strings.ToLower(key)
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, errors.Errorf("无效的机密变量键名模式: %s", key)
+		}
+		s.keys = append(s.keys, key)
+	}
+	s.Mark(paths...)
+	return s, nil
+}
+
+// Mark 将变量路径标记为机密，路径下的所有值都会被隐藏
+func (s *Secrets) Mark(paths ...string) {
+	for _, p := range paths {
+		if segments, err := parsePath(p); err == nil {
+			s.paths[canonicalPath(segments)] = true
+		}
+	}
+}
+
+// Paths 返回标记为机密的变量路径，按名称排序
+func (s *Secrets) Paths() []string {
+	return sortedKeys(s.paths)
+}
+
+// IsSecret 判断变量路径的值是否是机密的
+func (s *Secrets) IsSecret(p string) bool {
+	if s == nil {
+		return false
+	}
+	segments, err := parsePath(p)
+	if err != nil {
+		return false
+	}
+	current := ""
+	for _, seg := range segments {
+		if seg.index >= 0 {
+			current = IndexPath(current, seg.index)
+		} else {
+			if s.matchKey(seg.key) {
+				return true
+			}
+			current = joinEscaped(current, seg.key)
+		}
+		if s.paths[current] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Secrets) matchKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range s.keys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact 返回 vars 的副本，其中机密的值被替换为 RedactedValue，vars 本身不会被修改
+func (s *Secrets) Redact(vars map[string]interface{}) map[string]interface{} {
+	return s.redact("", vars).(map[string]interface{})
+}
+
+func (s *Secrets) redact(p string, v interface{}) interface{} {
+	if p != "" && s.IsSecret(p) {
+		return RedactedValue
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			result[k] = s.redact(joinEscaped(p, k), item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(t))
+		for i, item := range t {
+			result[i] = s.redact(IndexPath(p, i), item)
+		}
+		return result
+	}
+	return v
+}
+
+// RedactText 将文本中出现的机密字符串值替换为 RedactedValue，用于可能包含变量值的错误信息
+// 短于 4 个字符的值不会被替换
+func (s *Secrets) RedactText(vars map[string]interface{}, text string) string {
+	if s == nil {
+		return text
+	}
+	values := s.values("", vars, nil)
+	if len(values) == 0 {
+		return text
+	}
+	// 先替换较长的值，避免其中包含的较短的值先被替换
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	pairs := make([]string, 0, len(values)*2)
+	for _, value := range values {
+		pairs = append(pairs, value, RedactedValue)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// values 返回机密的字符串值，机密的映射和列表中的所有字符串也是机密的
+func (s *Secrets) values(p string, v interface{}, secret []string) []string {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			secret = s.values(joinEscaped(p, k), item, secret)
+		}
+	case []interface{}:
+		for i, item := range t {
+			secret = s.values(IndexPath(p, i), item, secret)
+		}
+	case string:
+		if len([]rune(t)) >= minRedactLength && s.IsSecret(p) {
+			secret = append(secret, t)
+		}
+	}
+	return secret
+}
+
+// secretPaths 返回 YAML 节点树中带有 !secret 标签的值的路径
+func secretPaths(node *yaml.Node, p string, paths []string) []string {
+	if node.Tag == SecretTag && p != "" {
+		return append(paths, p)
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			paths = secretPaths(child, p, paths)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				continue
+			}
+			paths = secretPaths(value, joinEscaped(p, key.Value), paths)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			paths = secretPaths(item, IndexPath(p, i), paths)
+		}
+	}
+	return paths
+}
+
+// stripSecretTags 移除节点树中的 !secret 标签，使值按普通 YAML 的规则解析类型
+func stripSecretTags(node *yaml.Node) {
+	if node.Tag == SecretTag {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		stripSecretTags(child)
+	}
+}
@@ -0,0 +1,331 @@
+package variables
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Override 表示一个命令行变量覆盖，例如 -set project.name=Foo
+type Override struct {
+	// 点分隔的变量路径，支持列表下标，例如 routes[0].name
+	Path string
+	// 设置的值
+	Value interface{}
+}
+
+// ParseSet 解析 -set 参数: name=value[,name=value...]
+// 值按类型解析（整数、浮点数、true/false、null），{a,b} 表示列表，\, 表示值中的逗号
+func ParseSet(s string) ([]Override, error) {
+	return parseAssignments(s, parseTypedValue)
+}
+
+// ParseSetString 解析 -set-string 参数: name=value[,name=value...]，值总是字符串
+// 值按原样使用，其中的 ${ 已用 EscapeInterpolation 转义，不会被 Resolve 展开
+func ParseSetString(s string) ([]Override, error) {
+	return parseAssignments(s, func(v string) (interface{}, error) {
+		return EscapeInterpolation(unescapeComma(v)), nil
+	})
+}
+
+// ParseSetJSON 解析 -set-json 参数: name=<json>，值按 JSON 解析
+func ParseSetJSON(s string) ([]Override, error) {
+	path, raw, err := splitAssignment(s)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, errors.Wrapf(err, "解析 %s 的 JSON 值失败", path)
+	}
+	return []Override{{Path: path, Value: NormalizeJSON(value)}}, nil
+}
+
+// ParseSetFile 解析 -set-file 参数: name=<文件路径>，值为文件内容
+// 相对路径相对于 baseDir
+func ParseSetFile(s, baseDir string) ([]Override, error) {
+	path, file, err := splitAssignment(s)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(file) && baseDir != "" {
+		file = filepath.Join(baseDir, file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取 %s 的值文件失败", path)
+	}
+	return []Override{{Path: path, Value: string(data)}}, nil
+}
+
+// ApplyOverrides 按顺序将覆盖值设置到 vars 中，后面的覆盖前面的
+// 覆盖值与现有值都是映射时深度合并，值为 ~delete 时删除对应的键
+func ApplyOverrides(vars map[string]interface{}, overrides []Override, opts MergeOptions) error {
+	for _, o := range overrides {
+		segments, err := parsePath(o.Path)
+		if err != nil {
+			return err
+		}
+		if _, err := setPath(vars, segments, o.Value, opts); err != nil {
+			return errors.Wrapf(err, "设置变量 %s 失败", o.Path)
+		}
+	}
+	return nil
+}
+
+// Get 返回变量路径（如 project.name、routes[0].path）对应的值
+func Get(vars map[string]interface{}, path string) (interface{}, bool) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	var current interface{} = vars
+	for _, seg := range segments {
+		if seg.index >= 0 {
+			list, ok := current.([]interface{})
+			if !ok || seg.index >= len(list) {
+				return nil, false
+			}
+			current = list[seg.index]
+			continue
+		}
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[seg.key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// pathSegment 变量路径中的一段，index >= 0 表示列表下标，否则为映射的键
+type pathSegment struct {
+	key   string
+	index int
+}
+
+// parsePath 解析变量路径，例如 a.b[0].c，\. 表示键中的点
+func parsePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	var key strings.Builder
+	hasKey := false
+	flush := func() {
+		if hasKey {
+			segments = append(segments, pathSegment{key: key.String(), index: -1})
+			key.Reset()
+			hasKey = false
+		}
+	}
+
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			if i+1 < len(path) {
+				i++
+				key.WriteByte(path[i])
+				hasKey = true
+			}
+		case '.':
+			if !hasKey && (i == 0 || path[i-1] != ']') {
+				return nil, errors.Errorf("无效的变量路径: %s", path)
+			}
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 || len(segments) == 0 {
+				return nil, errors.Errorf("无效的变量路径: %s", path)
+			}
+			index, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || index < 0 {
+				return nil, errors.Errorf("无效的列表下标: %s", path)
+			}
+			segments = append(segments, pathSegment{index: index})
+			i += end
+		default:
+			key.WriteByte(c)
+			hasKey = true
+		}
+	}
+	flush()
+
+	if len(segments) == 0 || strings.HasSuffix(path, ".") {
+		return nil, errors.Errorf("无效的变量路径: %s", path)
+	}
+	return segments, nil
+}
+
+// setPath 在 current 中设置路径对应的值，返回设置后的容器
+func setPath(current interface{}, segments []pathSegment, value interface{}, opts MergeOptions) (interface{}, error) {
+	seg := segments[0]
+	last := len(segments) == 1
+
+	if seg.index >= 0 {
+		list, _ := current.([]interface{})
+		for len(list) <= seg.index {
+			list = append(list, nil)
+		}
+		if last {
+			list[seg.index] = overrideValue(list[seg.index], value, opts)
+			return list, nil
+		}
+		child, err := setPath(list[seg.index], segments[1:], value, opts)
+		if err != nil {
+			return nil, err
+		}
+		list[seg.index] = child
+		return list, nil
+	}
+
+	m, ok := current.(map[string]interface{})
+	if !ok {
+		m = make(map[string]interface{})
+	}
+	if last {
+		if isDelete(value) {
+			delete(m, seg.key)
+		} else {
+			m[seg.key] = overrideValue(m[seg.key], value, opts)
+		}
+		return m, nil
+	}
+	child, err := setPath(m[seg.key], segments[1:], value, opts)
+	if err != nil {
+		return nil, err
+	}
+	m[seg.key] = child
+	return m, nil
+}
+
+// overrideValue 返回覆盖后的值，双方都是映射时深度合并
+func overrideValue(existing, value interface{}, opts MergeOptions) interface{} {
+	e, ok1 := existing.(map[string]interface{})
+	v, ok2 := value.(map[string]interface{})
+	if ok1 && ok2 {
+		return Merge(e, v, opts)
+	}
+	return copyValue(value, "", opts)
+}
+
+// parseAssignments 解析逗号分隔的多个 name=value
+func parseAssignments(s string, parseValue func(string) (interface{}, error)) ([]Override, error) {
+	var overrides []Override
+	for _, assignment := range splitUnescaped(s, ',') {
+		path, raw, err := splitAssignment(assignment)
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseValue(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "解析 %s 的值失败", path)
+		}
+		overrides = append(overrides, Override{Path: path, Value: value})
+	}
+	return overrides, nil
+}
+
+// splitAssignment 拆分 name=value
+func splitAssignment(s string) (string, string, error) {
+	path, value, ok := strings.Cut(s, "=")
+	path = strings.TrimSpace(path)
+	if !ok || path == "" {
+		return "", "", errors.Errorf("无效的变量设置 %q，格式应为 name=value", s)
+	}
+	return path, value, nil
+}
+
+// splitUnescaped 按未转义的分隔符拆分字符串，花括号内的分隔符不拆分
+// 拆分结果中保留转义符，由调用方解析值时去除
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	var sb strings.Builder
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == sep:
+			sb.WriteByte(c)
+			sb.WriteByte(sep)
+			i++
+		case c == '{':
+			depth++
+			sb.WriteByte(c)
+		case c == '}' && depth > 0:
+			depth--
+			sb.WriteByte(c)
+		case c == sep && depth == 0:
+			parts = append(parts, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return append(parts, sb.String())
+}
+
+// parseTypedValue 按类型解析 -set 的值
+func parseTypedValue(s string) (interface{}, error) {
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		inner := s[1 : len(s)-1]
+		list := []interface{}{}
+		if inner == "" {
+			return list, nil
+		}
+		for _, item := range splitUnescaped(inner, ',') {
+			v, err := parseTypedValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+
+	s = unescapeComma(s)
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	return s, nil
+}
+
+// NormalizeJSON 将 JSON 中的整数转换为 int，与 YAML 变量文件保持一致
+// JSON 不区分整数和浮点数，没有小数部分的数值都视为整数
+func NormalizeJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			t[k] = NormalizeJSON(item)
+		}
+	case []interface{}:
+		for i, item := range t {
+			t[i] = NormalizeJSON(item)
+		}
+	case float64:
+		if t == float64(int(t)) {
+			return int(t)
+		}
+	}
+	return v
+}
+
+// unescapeComma 将 \, 还原为逗号
+func unescapeComma(s string) string {
+	return strings.ReplaceAll(s, `\,`, ",")
+}
@@ -0,0 +1,199 @@
+package variables
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSet(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []Override
+		wantErr bool
+	}{
+		{"project.name=Foo", []Override{{"project.name", "Foo"}}, false},
+		{"a=1,b=1.5,c=true,d=null", []Override{{"a", 1}, {"b", 1.5}, {"c", true}, {"d", nil}}, false},
+		{"tags={a,b,3}", []Override{{"tags", []interface{}{"a", "b", 3}}}, false},
+		{"tags={}", []Override{{"tags", []interface{}{}}}, false},
+		{`msg=a\,b,x=y`, []Override{{"msg", "a,b"}, {"x", "y"}}, false},
+		{"empty=", []Override{{"empty", ""}}, false},
+		{"url=http://x?a=b", []Override{{"url", "http://x?a=b"}}, false},
+		{"novalue", nil, true},
+		{"=value", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSet(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSet(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSet(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseSetString(t *testing.T) {
+	got, err := ParseSetString(`version=1.10,flag=true,list={a\,b}`)
+	if err != nil {
+		t.Fatalf("ParseSetString() error = %v", err)
+	}
+	want := []Override{{"version", "1.10"}, {"flag", "true"}, {"list", "{a,b}"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSetString() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseSetString_Literal(t *testing.T) {
+	// -set-string 的值中的 ${ 不展开
+	overrides, err := ParseSetString(`cmd=echo ${HOME} $${USER},name=${project}`)
+	if err != nil {
+		t.Fatalf("ParseSetString() error = %v", err)
+	}
+	vars := map[string]interface{}{"project": "demo"}
+	if err := ApplyOverrides(vars, overrides, MergeOptions{}); err != nil {
+		t.Fatalf("ApplyOverrides() error = %v", err)
+	}
+	if err := Resolve(vars, nil, nil); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	want := map[string]interface{}{"project": "demo", "cmd": "echo ${HOME} $${USER}", "name": "${project}"}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("vars = %#v, want %#v", vars, want)
+	}
+}
+
+func TestParseSetJSON(t *testing.T) {
+	got, err := ParseSetJSON(`routes=[{"name":"users","port":8080},{"name":"posts","ratio":0.5}]`)
+	if err != nil {
+		t.Fatalf("ParseSetJSON() error = %v", err)
+	}
+	want := []Override{{"routes", []interface{}{
+		map[string]interface{}{"name": "users", "port": 8080},
+		map[string]interface{}{"name": "posts", "ratio": 0.5},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSetJSON() = %#v, want %#v", got, want)
+	}
+
+	if _, err := ParseSetJSON(`routes=[`); err == nil {
+		t.Error("ParseSetJSON() expected error for invalid JSON")
+	}
+}
+
+func TestParseSetFile(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "set-file-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := os.WriteFile(filepath.Join(tempDir, "LICENSE"), []byte("MIT\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// 相对路径相对于 baseDir
+	got, err := ParseSetFile("license=LICENSE", tempDir)
+	if err != nil {
+		t.Fatalf("ParseSetFile() error = %v", err)
+	}
+	want := []Override{{"license", "MIT\n"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSetFile() = %#v, want %#v", got, want)
+	}
+
+	if _, err := ParseSetFile("license=missing", tempDir); err == nil {
+		t.Error("ParseSetFile() expected error for missing file")
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		vars      string
+		overrides []Override
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "set nested value",
+			vars:      "project:\n  name: old\n  version: 1\n",
+			overrides: []Override{{"project.name", "Foo"}},
+			want:      "project:\n  name: Foo\n  version: 1\n",
+		},
+		{
+			name:      "create missing maps",
+			vars:      "a: 1\n",
+			overrides: []Override{{"b.c.d", true}},
+			want:      "a: 1\nb:\n  c:\n    d: true\n",
+		},
+		{
+			name:      "deep merge map value",
+			vars:      "db:\n  host: localhost\n  port: 5432\n",
+			overrides: []Override{{"db", map[string]interface{}{"port": 6543}}},
+			want:      "db:\n  host: localhost\n  port: 6543\n",
+		},
+		{
+			name:      "replace list",
+			vars:      "tags: [a, b]\n",
+			overrides: []Override{{"tags", []interface{}{"c"}}},
+			want:      "tags: [c]\n",
+		},
+		{
+			name:      "set list item field",
+			vars:      "routes:\n  - {name: users, path: /users}\n",
+			overrides: []Override{{"routes[0].path", "/members"}, {"routes[1].name", "posts"}},
+			want:      "routes:\n  - {name: users, path: /members}\n  - {name: posts}\n",
+		},
+		{
+			name:      "escaped dot in key",
+			vars:      "{}\n",
+			overrides: []Override{{`annotations.app\.io/name`, "x"}},
+			want:      "annotations:\n  app.io/name: x\n",
+		},
+		{
+			name:      "later overrides win",
+			vars:      "a: 1\n",
+			overrides: []Override{{"a", 2}, {"a", 3}},
+			want:      "a: 3\n",
+		},
+		{
+			name:      "delete sentinel",
+			vars:      "a: 1\nb: 2\n",
+			overrides: []Override{{"a", DeleteSentinel}},
+			want:      "b: 2\n",
+		},
+		{
+			name:      "invalid path",
+			vars:      "{}\n",
+			overrides: []Override{{"a..b", 1}},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid index",
+			vars:      "{}\n",
+			overrides: []Override{{"a[x]", 1}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := parseYAML(t, tt.vars)
+			err := ApplyOverrides(vars, tt.overrides, MergeOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyOverrides() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			want := parseYAML(t, tt.want)
+			if !reflect.DeepEqual(vars, want) {
+				t.Errorf("ApplyOverrides() = %v, want %v", vars, want)
+			}
+		})
+	}
+}